@@ -0,0 +1,127 @@
+// Package analysis measures the rhythmic feel of drum patterns.
+package analysis
+
+import (
+	"github.com/mattetti/drumbeat"
+)
+
+// BackbeatThreshold is the Backbeat ratio from which the patterns are
+// considered to have a backbeat.
+const BackbeatThreshold = 0.75
+
+// Stats are the metrics of a single pattern or of the combined patterns.
+type Stats struct {
+	Name       string
+	Instrument drumbeat.Instrument
+	Onsets     int
+	// Density is the ratio of steps being played.
+	Density float64
+	// Syncopation is the Longuet-Higgins and Lee index.
+	Syncopation float64
+	// WNBD is the weighted note to beat distance.
+	WNBD float64
+	// Entropy of the onset positions within the beats, in bits.
+	Entropy  float64
+	Evenness float64
+	// IOI counts the inter-onset intervals in steps.
+	IOI map[int]int
+}
+
+// Report holds the metrics of a set of patterns.
+type Report struct {
+	Grid  drumbeat.GridRes
+	Steps int
+	// Weights are the metrical weights of the steps of a bar.
+	Weights []int
+	// Patterns are the metrics of each pattern.
+	Patterns []Stats
+	// Combined are the metrics of the onsets of all the patterns merged.
+	Combined Stats
+	// Density is the density of each instrument, the patterns playing the
+	// same instrument are merged.
+	Density map[drumbeat.Instrument]float64
+	// Backbeat is the ratio of the second and fourth beats played by a
+	// snare, clap or rimshot, HasBackbeat is set from BackbeatThreshold.
+	Backbeat    float64
+	HasBackbeat bool
+}
+
+// Analyze computes the metrics of the patterns. The patterns are expected to
+// be in 4/4 and to share the grid of the first pattern. They are analyzed
+// realigned, without being modified.
+func Analyze(patterns ...*drumbeat.Pattern) *Report {
+	r := &Report{Density: map[drumbeat.Instrument]float64{}}
+	patterns = realigned(patterns)
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		if r.Grid == "" {
+			r.Grid = p.Grid
+		}
+		if len(p.Pulses) > r.Steps {
+			r.Steps = len(p.Pulses)
+		}
+	}
+	if r.Grid == "" {
+		return r
+	}
+	r.Weights = MetricWeights(r.Grid)
+
+	combined := make([]bool, r.Steps)
+	instruments := map[drumbeat.Instrument][]bool{}
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		onsets := Onsets(p)
+		st := stats(onsets, r.Grid)
+		st.Name = p.Name
+		st.Instrument = p.Instrument()
+		r.Patterns = append(r.Patterns, st)
+
+		inst := instruments[st.Instrument]
+		if inst == nil {
+			inst = make([]bool, r.Steps)
+			instruments[st.Instrument] = inst
+		}
+		for i, on := range onsets {
+			inst[i] = inst[i] || on
+			combined[i] = combined[i] || on
+		}
+	}
+	for inst, onsets := range instruments {
+		r.Density[inst] = Density(onsets)
+	}
+	r.Combined = stats(combined, r.Grid)
+	r.Backbeat = Backbeat(patterns...)
+	r.HasBackbeat = r.Backbeat >= BackbeatThreshold
+	return r
+}
+
+// realigned returns realigned copies of the patterns.
+func realigned(patterns []*drumbeat.Pattern) []*drumbeat.Pattern {
+	copies := make([]*drumbeat.Pattern, len(patterns))
+	for i, p := range patterns {
+		if p == nil {
+			continue
+		}
+		c := *p
+		c.Pulses = append(drumbeat.Pulses(nil), p.Pulses...)
+		c.ReAlign()
+		copies[i] = &c
+	}
+	return copies
+}
+
+func stats(onsets []bool, grid drumbeat.GridRes) Stats {
+	return Stats{
+		Onsets:      count(onsets),
+		Density:     Density(onsets),
+		Syncopation: Syncopation(onsets, grid),
+		WNBD:        WNBD(onsets, grid),
+		Entropy:     Entropy(onsets, grid),
+		Evenness:    Evenness(onsets),
+		IOI:         IOIHistogram(onsets),
+	}
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestAnalyze(t *testing.T) {
+	patterns := drumbeat.NewFromString(drumbeat.One16, `
+		[kick]	{C1}	x.x.......xx...x;
+		[snare]	{D1}	....x.......x...;
+		[rim]	{C#1}	..............x.;
+		[hihat]	{F#1}	x.x.x.x.x.x.x.x.`)
+	r := Analyze(patterns...)
+	if r.Grid != drumbeat.One16 || r.Steps != 16 || len(r.Weights) != 16 {
+		t.Fatalf("unexpected grid %s, %d steps and %d weights", r.Grid, r.Steps, len(r.Weights))
+	}
+	if len(r.Patterns) != 4 {
+		t.Fatalf("expected the stats of 4 patterns, got %d", len(r.Patterns))
+	}
+	if st := r.Patterns[0]; st.Name != "kick" || st.Instrument != drumbeat.Kick || st.Onsets != 5 {
+		t.Errorf("unexpected kick stats %+v", st)
+	}
+	wantDensity := map[drumbeat.Instrument]float64{
+		drumbeat.Kick:        5.0 / 16,
+		drumbeat.Snare:       2.0 / 16,
+		drumbeat.Rimshot:     1.0 / 16,
+		drumbeat.ClosedHiHat: 0.5,
+	}
+	for inst, want := range wantDensity {
+		if got := r.Density[inst]; got != want {
+			t.Errorf("expected a %s density of %v, got %v", inst, want, got)
+		}
+	}
+	// the hihats cover all the even steps, the kick adds 11 and 15
+	if r.Combined.Onsets != 10 {
+		t.Errorf("expected 10 combined onsets, got %d", r.Combined.Onsets)
+	}
+	if r.Patterns[0].Syncopation <= 0 {
+		t.Errorf("expected the kick to be syncopated, got %v", r.Patterns[0].Syncopation)
+	}
+	// the hihats fill the rests following the kicks off the beat
+	if r.Combined.Syncopation != 0 || r.Combined.WNBD != 1.6 {
+		t.Errorf("unexpected combined syncopation %v and WNBD %v", r.Combined.Syncopation, r.Combined.WNBD)
+	}
+	if !r.HasBackbeat || r.Backbeat != 1 {
+		t.Errorf("expected a backbeat, got %v", r.Backbeat)
+	}
+
+	short := drumbeat.NewFromString(drumbeat.One16, "[snare]\t{D1}\t....x...")[0]
+	if r := Analyze(short); r.Steps != 16 || len(short.Pulses) != 8 {
+		t.Errorf("expected a full bar to be analyzed without modifying the pattern, got %d steps and %d pulses", r.Steps, len(short.Pulses))
+	}
+
+	if r := Analyze(); r.Steps != 0 || len(r.Patterns) != 0 {
+		t.Errorf("expected an empty report, got %+v", r)
+	}
+}
@@ -0,0 +1,228 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// Onsets returns the steps of the pattern being played, the pulses without
+// velocity are ignored.
+func Onsets(p *drumbeat.Pattern) []bool {
+	if p == nil {
+		return nil
+	}
+	onsets := make([]bool, len(p.Pulses))
+	for i, pulse := range p.Pulses {
+		onsets[i] = pulse != nil && pulse.Velocity > 0
+	}
+	return onsets
+}
+
+// MetricWeights returns the Longuet-Higgins and Lee metrical weight of each
+// step of a 4/4 bar: 0 for the downbeat, -1 for the middle of the bar, -2 for
+// the other beats and one less for each subdivision of the beats.
+func MetricWeights(grid drumbeat.GridRes) []int {
+	return metricWeights(int(grid.StepsInBeat()))
+}
+
+func metricWeights(stepsInBeat int) []int {
+	steps := stepsInBeat * 4
+	levels := 0
+	for n := steps; n > 1; n /= 2 {
+		levels++
+	}
+	weights := make([]int, steps)
+	for i := 1; i < steps; i++ {
+		// the steps on stronger levels are divisible by larger powers of 2
+		level := levels
+		for n := i; n%2 == 0; n /= 2 {
+			level--
+		}
+		weights[i] = -level
+	}
+	return weights
+}
+
+// Density returns the ratio of steps being played.
+func Density(onsets []bool) float64 {
+	if len(onsets) == 0 {
+		return 0
+	}
+	return float64(count(onsets)) / float64(len(onsets))
+}
+
+// Syncopation returns the Longuet-Higgins and Lee syncopation index of the
+// onsets. Each onset followed by a silence on a stronger metrical position
+// before the next onset adds the weight difference, so 0 means that the
+// onsets never anticipate the stronger positions. The onsets loop.
+func Syncopation(onsets []bool, grid drumbeat.GridRes) float64 {
+	weights := MetricWeights(grid)
+	n := len(onsets)
+	if count(onsets) == 0 || len(weights) == 0 {
+		return 0
+	}
+	var total float64
+	for i, on := range onsets {
+		if !on {
+			continue
+		}
+		strongest := math.MinInt32
+		for j := 1; j < n && !onsets[(i+j)%n]; j++ {
+			if w := weights[(i+j)%n%len(weights)]; w > strongest {
+				strongest = w
+			}
+		}
+		if w := weights[i%len(weights)]; strongest > w {
+			total += float64(strongest - w)
+		}
+	}
+	return total
+}
+
+// WNBD returns the weighted note to beat distance of the onsets: the average
+// of the inverse distance, in beats, of each onset to its closest beat. The
+// distance counts double when the note crosses the next beat, the notes last
+// until the next onset. Onsets on the beats don't add syncopation.
+func WNBD(onsets []bool, grid drumbeat.GridRes) float64 {
+	stepsInBeat := int(grid.StepsInBeat())
+	n := len(onsets)
+	notes := count(onsets)
+	if notes == 0 {
+		return 0
+	}
+	var total float64
+	for i, on := range onsets {
+		if !on || i%stepsInBeat == 0 {
+			continue
+		}
+		offset := i % stepsInBeat
+		dist := float64(offset) / float64(stepsInBeat)
+		if d := 1 - dist; d < dist {
+			dist = d
+		}
+		next := 1
+		for next < n && !onsets[(i+next)%n] {
+			next++
+		}
+		nextBeat := stepsInBeat - offset
+		if next > nextBeat {
+			total += 2 / dist
+		} else {
+			total += 1 / dist
+		}
+	}
+	return total / float64(notes)
+}
+
+// Entropy returns the Shannon entropy in bits of the distribution of the
+// onsets over the positions within a beat. It's 0 when all the onsets share
+// the same position, such as four on the floor, and reaches the log2 of the
+// number of steps in a beat when they're evenly spread.
+func Entropy(onsets []bool, grid drumbeat.GridRes) float64 {
+	stepsInBeat := int(grid.StepsInBeat())
+	counts := make([]int, stepsInBeat)
+	for i, on := range onsets {
+		if on {
+			counts[i%stepsInBeat]++
+		}
+	}
+	total := float64(count(onsets))
+	var entropy float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// IOIHistogram counts the inter-onset intervals in steps, the interval
+// between the last and the first onsets wraps around the pattern.
+func IOIHistogram(onsets []bool) map[int]int {
+	hist := map[int]int{}
+	positions := positions(onsets)
+	for i, pos := range positions {
+		next := positions[(i+1)%len(positions)]
+		if next <= pos {
+			next += len(onsets)
+		}
+		hist[next-pos]++
+	}
+	return hist
+}
+
+// Evenness returns how evenly the onsets are spread over the pattern, from 1
+// when they're equally spaced to 0. It's the magnitude of the DFT coefficient
+// of the onsets matching their number, so euclidean rhythms are close to 1.
+func Evenness(onsets []bool) float64 {
+	positions := positions(onsets)
+	k := len(positions)
+	if k == 0 {
+		return 0
+	}
+	var sum complex128
+	for _, pos := range positions {
+		sum += cmplx.Exp(complex(0, 2*math.Pi*float64(k*pos)/float64(len(onsets))))
+	}
+	return cmplx.Abs(sum) / float64(k)
+}
+
+// Backbeat returns the ratio of the second and fourth beats of the bars
+// played by a snare, a clap or a rimshot.
+func Backbeat(patterns ...*drumbeat.Pattern) float64 {
+	var steps int
+	var grid drumbeat.GridRes
+	for _, p := range patterns {
+		if p != nil && len(p.Pulses) > steps {
+			steps, grid = len(p.Pulses), p.Grid
+		}
+	}
+	stepsInBeat := int(grid.StepsInBeat())
+	var hits, beats int
+	for beat := 1; beat*stepsInBeat < steps; beat += 2 {
+		beats++
+		step := beat * stepsInBeat
+		for _, p := range patterns {
+			if p == nil || p.Grid != grid || step >= len(p.Pulses) {
+				continue
+			}
+			switch p.Instrument() {
+			case drumbeat.Snare, drumbeat.Clap, drumbeat.Rimshot:
+			default:
+				continue
+			}
+			if pulse := p.Pulses[step]; pulse != nil && pulse.Velocity > 0 {
+				hits++
+				break
+			}
+		}
+	}
+	if beats == 0 {
+		return 0
+	}
+	return float64(hits) / float64(beats)
+}
+
+func count(onsets []bool) int {
+	var n int
+	for _, on := range onsets {
+		if on {
+			n++
+		}
+	}
+	return n
+}
+
+func positions(onsets []bool) []int {
+	pos := []int{}
+	for i, on := range onsets {
+		if on {
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestMetricWeights(t *testing.T) {
+	tests := []struct {
+		grid drumbeat.GridRes
+		want []int
+	}{
+		{drumbeat.One4, []int{0, -2, -1, -2}},
+		{drumbeat.One8, []int{0, -3, -2, -3, -1, -3, -2, -3}},
+		{drumbeat.One16, []int{0, -4, -3, -4, -2, -4, -3, -4, -1, -4, -3, -4, -2, -4, -3, -4}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.grid), func(t *testing.T) {
+			if got := MetricWeights(tt.grid); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		density     float64
+		syncopation float64
+		wnbd        float64
+		entropy     float64
+		evenness    float64
+		ioi         map[int]int
+	}{
+		{name: "four on the floor",
+			pattern:  "x...x...x...x...",
+			density:  0.25,
+			evenness: 1,
+			ioi:      map[int]int{4: 4},
+		},
+		{name: "son clave",
+			pattern:     "x..x..x...x.x...",
+			density:     5.0 / 16,
+			syncopation: 4,
+			wnbd:        2.8,
+			entropy:     1.5219,
+			evenness:    0.7226,
+			ioi:         map[int]int{2: 1, 3: 2, 4: 2},
+		},
+		{name: "offbeats",
+			pattern:     "..x...x...x...x.",
+			density:     0.25,
+			syncopation: 7,
+			wnbd:        4,
+			evenness:    1,
+			ioi:         map[int]int{4: 4},
+		},
+		{name: "empty",
+			pattern: "................",
+			ioi:     map[int]int{},
+		},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.0001 }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onsets := Onsets(drumbeat.NewFromString(drumbeat.One16, tt.pattern)[0])
+			if got := Density(onsets); !near(got, tt.density) {
+				t.Errorf("expected a density of %v, got %v", tt.density, got)
+			}
+			if got := Syncopation(onsets, drumbeat.One16); !near(got, tt.syncopation) {
+				t.Errorf("expected a syncopation of %v, got %v", tt.syncopation, got)
+			}
+			if got := WNBD(onsets, drumbeat.One16); !near(got, tt.wnbd) {
+				t.Errorf("expected a WNBD of %v, got %v", tt.wnbd, got)
+			}
+			if got := Entropy(onsets, drumbeat.One16); !near(got, tt.entropy) {
+				t.Errorf("expected an entropy of %v, got %v", tt.entropy, got)
+			}
+			if got := Evenness(onsets); !near(got, tt.evenness) {
+				t.Errorf("expected an evenness of %v, got %v", tt.evenness, got)
+			}
+			if got := IOIHistogram(onsets); !reflect.DeepEqual(got, tt.ioi) {
+				t.Errorf("expected the IOI histogram %v, got %v", tt.ioi, got)
+			}
+		})
+	}
+}
+
+func TestBackbeat(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		want     float64
+	}{
+		{name: "backbeat",
+			patterns: `
+				[kick]	x.......x.......;
+				[snare]	....x.......x...`,
+			want: 1},
+		{name: "clap on 2 bars",
+			patterns: `
+				[kick]	x...x...x...x...	x...x...x...x...;
+				[clap]	....x...........	....x.......x...`,
+			want: 0.75},
+		{name: "half time",
+			patterns: `
+				[kick]	x...............;
+				[snare]	........x.......`,
+			want: 0},
+		{name: "no snare",
+			patterns: `[kick]	x...x...x...x...`,
+			want:     0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backbeat(drumbeat.NewFromString(drumbeat.One16, tt.patterns)...); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package analysis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// Rhythm are the onsets of a pattern on its grid. Rhythms using different
+// grids or lengths can be compared, the coarser rhythm is converted to the
+// finer grid and the shorter one is looped.
+type Rhythm struct {
+	StepsInBeat int
+	Onsets      []bool
+}
+
+// NewRhythm returns the rhythm played by the pattern.
+func NewRhythm(p *drumbeat.Pattern) Rhythm {
+	if p == nil {
+		return Rhythm{StepsInBeat: 1}
+	}
+	return Rhythm{StepsInBeat: int(p.Grid.StepsInBeat()), Onsets: Onsets(p)}
+}
+
+// at converts the rhythm to a finer grid and loops it to fill the steps.
+func (r Rhythm) at(stepsInBeat, steps int) []bool {
+	onsets := make([]bool, steps)
+	if r.StepsInBeat < 1 {
+		return onsets
+	}
+	ratio := stepsInBeat / r.StepsInBeat
+	length := len(r.Onsets) * ratio
+	for i, on := range r.Onsets {
+		if !on {
+			continue
+		}
+		for pos := i * ratio; pos < steps; pos += length {
+			onsets[pos] = true
+		}
+	}
+	return onsets
+}
+
+// align converts the rhythms to the same grid and length.
+func align(a, b Rhythm) (x, y []bool, stepsInBeat int) {
+	stepsInBeat = a.StepsInBeat
+	if b.StepsInBeat > stepsInBeat {
+		stepsInBeat = b.StepsInBeat
+	}
+	if stepsInBeat < 1 {
+		stepsInBeat = 1
+	}
+	la := len(a.Onsets) * (stepsInBeat / max(a.StepsInBeat, 1))
+	lb := len(b.Onsets) * (stepsInBeat / max(b.StepsInBeat, 1))
+	return a.at(stepsInBeat, max(la, lb)), b.at(stepsInBeat, max(la, lb)), stepsInBeat
+}
+
+// Distance measures how different two rhythms are, 0 meaning identical.
+type Distance func(a, b Rhythm) float64
+
+// Hamming returns the number of steps played by only one of the rhythms.
+func Hamming(a, b Rhythm) float64 {
+	x, y, _ := align(a, b)
+	return float64(hamming(x, y, 0))
+}
+
+// hamming compares x to y rotated to the left by shift steps.
+func hamming(x, y []bool, shift int) int {
+	var d int
+	for i := range x {
+		if x[i] != y[(i+shift)%len(y)] {
+			d++
+		}
+	}
+	return d
+}
+
+// RotationDistance is the smallest Hamming distance between a and the
+// rotations of b, so a rhythm and its displaced versions are identical.
+func RotationDistance(a, b Rhythm) float64 {
+	x, y, _ := align(a, b)
+	if len(x) == 0 {
+		return 0
+	}
+	best := len(x)
+	for shift := range y {
+		if d := hamming(x, y, shift); d < best {
+			best = d
+		}
+	}
+	return float64(best)
+}
+
+// SwapDistance is the generalized swap distance in beats: the cost of
+// turning a into b when moving an onset costs the number of beats it's moved
+// and adding or removing an onset costs a beat. When the rhythms have the
+// same number of onsets close to each other, it's the number of swaps of
+// adjacent steps needed, expressed in beats.
+func SwapDistance(a, b Rhythm) float64 {
+	x, y, stepsInBeat := align(a, b)
+	pa, pb := positions(x), positions(y)
+	// cost[i][j] is the cost of turning the first i onsets of a into the
+	// first j onsets of b.
+	cost := make([][]float64, len(pa)+1)
+	for i := range cost {
+		cost[i] = make([]float64, len(pb)+1)
+		cost[i][0] = float64(i)
+	}
+	for j := range cost[0] {
+		cost[0][j] = float64(j)
+	}
+	for i := 1; i <= len(pa); i++ {
+		for j := 1; j <= len(pb); j++ {
+			move := math.Abs(float64(pa[i-1]-pb[j-1])) / float64(stepsInBeat)
+			cost[i][j] = math.Min(cost[i-1][j-1]+move, math.Min(cost[i-1][j], cost[i][j-1])+1)
+		}
+	}
+	return cost[len(pa)][len(pb)]
+}
+
+// MetricDistance is a Hamming distance weighted by the metrical weights of
+// the steps: a difference on the first beat of a bar costs 1 and the finer
+// subdivisions cost less, down to 1/(levels+1) for the finest steps.
+func MetricDistance(a, b Rhythm) float64 {
+	x, y, stepsInBeat := align(a, b)
+	weights := metricWeights(stepsInBeat)
+	levels := float64(-weights[1])
+	var d float64
+	for i := range x {
+		if x[i] != y[i] {
+			d += (float64(weights[i%len(weights)]) + levels + 1) / (levels + 1)
+		}
+	}
+	return d
+}
+
+// BeatDistance compares multi-instrument beats by summing the distances of
+// their instruments, the patterns playing the same instrument are merged and
+// a missing instrument is compared to silence.
+func BeatDistance(a, b []*drumbeat.Pattern, dist Distance) float64 {
+	la, lb := lanes(a), lanes(b)
+	var d float64
+	for name, ra := range la {
+		rb, ok := lb[name]
+		if !ok {
+			rb = Rhythm{StepsInBeat: ra.StepsInBeat, Onsets: make([]bool, len(ra.Onsets))}
+		}
+		d += dist(ra, rb)
+	}
+	for name, rb := range lb {
+		if _, ok := la[name]; !ok {
+			d += dist(Rhythm{StepsInBeat: rb.StepsInBeat, Onsets: make([]bool, len(rb.Onsets))}, rb)
+		}
+	}
+	return d
+}
+
+// lanes merges the rhythms of the patterns per instrument, the patterns with
+// an unknown instrument are identified by their key.
+func lanes(patterns []*drumbeat.Pattern) map[string]Rhythm {
+	lanes := map[string]Rhythm{}
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		name := string(p.Instrument())
+		if name == "" {
+			name = fmt.Sprintf("key %d", p.Key)
+		}
+		r := NewRhythm(p)
+		if existing, ok := lanes[name]; ok {
+			x, y, stepsInBeat := align(existing, r)
+			for i := range x {
+				x[i] = x[i] || y[i]
+			}
+			r = Rhythm{StepsInBeat: stepsInBeat, Onsets: x}
+		}
+		lanes[name] = r
+	}
+	return lanes
+}
+
+// Match is a beat found by Nearest.
+type Match struct {
+	// Index of the beat in the collection.
+	Index    int
+	Distance float64
+}
+
+// Nearest returns the k beats of the collection closest to the query, sorted
+// by distance.
+func Nearest(query []*drumbeat.Pattern, collection [][]*drumbeat.Pattern, k int, dist Distance) []Match {
+	matches := make([]Match, len(collection))
+	for i, beat := range collection {
+		matches[i] = Match{Index: i, Distance: BeatDistance(query, beat, dist)}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+	if k >= 0 && k < len(matches) {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// Fingerprint returns an identifier of the rhythm of the patterns which
+// doesn't depend on their PPQN, grid, rotation or number of repetitions, so
+// duplicated beats share the same fingerprint. The velocities and nudges are
+// ignored.
+func Fingerprint(patterns ...*drumbeat.Pattern) string {
+	lanes := lanes(patterns)
+	names := make([]string, 0, len(lanes))
+	stepsInBeat := 1
+	for name, r := range lanes {
+		names = append(names, name)
+		if r.StepsInBeat > stepsInBeat {
+			stepsInBeat = r.StepsInBeat
+		}
+	}
+	sort.Strings(names)
+	var steps int
+	for _, r := range lanes {
+		if l := len(r.Onsets) * stepsInBeat / max(r.StepsInBeat, 1); l > steps {
+			steps = l
+		}
+	}
+	onsets := make([][]bool, len(names))
+	all := make([]bool, steps)
+	for i, name := range names {
+		onsets[i] = lanes[name].at(stepsInBeat, steps)
+		for j, on := range onsets[i] {
+			all[j] = all[j] || on
+		}
+	}
+
+	// the onsets are moved to the downbeat one after the other to find the
+	// rotation allowing the coarsest grid and sorting first
+	shifts := positions(all)
+	if len(shifts) == 0 {
+		shifts = []int{0}
+	}
+	best, bestRes := "", 0
+	for _, shift := range shifts {
+		rotated := make([][]bool, len(onsets))
+		for i, lane := range onsets {
+			rotated[i] = append(append([]bool{}, lane[shift:]...), lane[:shift]...)
+		}
+		res, s := canonical(names, rotated, stepsInBeat)
+		if bestRes == 0 || res < bestRes || (res == bestRes && s < best) {
+			best, bestRes = s, res
+		}
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d|%s", bestRes, best)))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonical reduces the lanes to the coarsest grid and to their shortest
+// period and encodes them.
+func canonical(names []string, lanes [][]bool, stepsInBeat int) (int, string) {
+	steps := 0
+	if len(lanes) > 0 {
+		steps = len(lanes[0])
+	}
+	for stepsInBeat > 1 && steps%2 == 0 && onEvenSteps(lanes) {
+		for i, lane := range lanes {
+			half := make([]bool, len(lane)/2)
+			for j := range half {
+				half[j] = lane[j*2]
+			}
+			lanes[i] = half
+		}
+		stepsInBeat /= 2
+		steps /= 2
+	}
+	for period := 1; period < steps; period++ {
+		if steps%period == 0 && repeats(lanes, period) {
+			for i := range lanes {
+				lanes[i] = lanes[i][:period]
+			}
+			break
+		}
+	}
+	var sb strings.Builder
+	for i, lane := range lanes {
+		sb.WriteString(names[i] + ":")
+		for _, on := range lane {
+			if on {
+				sb.WriteByte('x')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte(';')
+	}
+	return stepsInBeat, sb.String()
+}
+
+// onEvenSteps returns true if all the onsets are on even steps.
+func onEvenSteps(lanes [][]bool) bool {
+	for _, lane := range lanes {
+		for i := 1; i < len(lane); i += 2 {
+			if lane[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// repeats returns true if the lanes repeat every period steps.
+func repeats(lanes [][]bool, period int) bool {
+	for _, lane := range lanes {
+		for i := period; i < len(lane); i++ {
+			if lane[i] != lane[i-period] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
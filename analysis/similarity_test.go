@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func rhythm(grid drumbeat.GridRes, s string) Rhythm {
+	return NewRhythm(drumbeat.NewFromString(grid, s)[0])
+}
+
+func TestDistances(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Rhythm
+		hamming  float64
+		rotation float64
+		swap     float64
+		metric   float64
+	}{
+		{name: "identical",
+			a: rhythm(drumbeat.One16, "x..x..x...x.x..."),
+			b: rhythm(drumbeat.One16, "x..x..x...x.x..."),
+		},
+		{name: "rotated",
+			a:        rhythm(drumbeat.One16, "x...x...x...x..."),
+			b:        rhythm(drumbeat.One16, "..x...x...x...x."),
+			hamming:  8,
+			rotation: 0,
+			swap:     2,
+			metric:   1 + 0.6 + 0.8 + 0.6 + 4*0.4,
+		},
+		{name: "moved by a step",
+			a:        rhythm(drumbeat.One16, "x..x..x...x.x..."),
+			b:        rhythm(drumbeat.One16, "x..x...x..x.x..."),
+			hamming:  2,
+			rotation: 2,
+			swap:     0.25,
+			metric:   0.4 + 0.2,
+		},
+		{name: "added onset",
+			a:        rhythm(drumbeat.One16, "x.......x......."),
+			b:        rhythm(drumbeat.One16, "x...x...x......."),
+			hamming:  1,
+			rotation: 1,
+			swap:     1,
+			metric:   0.6,
+		},
+		{name: "different grids",
+			a: rhythm(drumbeat.One4, "xxxx"),
+			b: rhythm(drumbeat.One16, "x...x...x...x..."),
+		},
+		{name: "looped",
+			a: rhythm(drumbeat.One16, "x...x...x...x...x...x...x...x..."),
+			b: rhythm(drumbeat.One16, "x...x...x...x..."),
+		},
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.0001 }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hamming(tt.a, tt.b); !near(got, tt.hamming) {
+				t.Errorf("expected a Hamming distance of %v, got %v", tt.hamming, got)
+			}
+			if got := RotationDistance(tt.a, tt.b); !near(got, tt.rotation) {
+				t.Errorf("expected a rotation distance of %v, got %v", tt.rotation, got)
+			}
+			if got := SwapDistance(tt.a, tt.b); !near(got, tt.swap) {
+				t.Errorf("expected a swap distance of %v, got %v", tt.swap, got)
+			}
+			if got := MetricDistance(tt.a, tt.b); !near(got, tt.metric) {
+				t.Errorf("expected a metric distance of %v, got %v", tt.metric, got)
+			}
+		})
+	}
+}
+
+func TestNearest(t *testing.T) {
+	parse := func(s string) []*drumbeat.Pattern { return drumbeat.NewFromString(drumbeat.One16, s) }
+	query := parse("[kick]\tx.......x.......;\n[snare]\t....x.......x...")
+	collection := [][]*drumbeat.Pattern{
+		parse("[kick]\tx...x...x...x..."),
+		parse("[snare]\t....x.......x...;\n[kick]\tx.......x......."),
+		parse("[kick]\tx.......x.....x.;\n[snare]\t....x.......x..."),
+		parse("[hihat]\tx.x.x.x.x.x.x.x."),
+	}
+	matches := Nearest(query, collection, 3, Hamming)
+	want := []Match{{Index: 1}, {Index: 2, Distance: 1}, {Index: 0, Distance: 4}}
+	if len(matches) != len(want) {
+		t.Fatalf("expected %d matches, got %d", len(want), len(matches))
+	}
+	for i, m := range matches {
+		if m != want[i] {
+			t.Errorf("expected match %d to be %+v, got %+v", i, want[i], m)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	beat := drumbeat.NewFromString(drumbeat.One16, "[kick]\tx.......x.......;\n[snare]\t....x.......x...")
+	want := Fingerprint(beat...)
+
+	same := map[string][]*drumbeat.Pattern{
+		"reordered": drumbeat.NewFromString(drumbeat.One16, "[snare]\t....x.......x...;\n[kick]\tx.......x......."),
+		"rotated":   drumbeat.NewFromString(drumbeat.One16, "[kick]\t..x.......x.....;\n[snare]\t......x.......x."),
+		"coarser":   drumbeat.NewFromString(drumbeat.One4, "[kick]\tx.x.;\n[snare]\t.x.x"),
+		"finer":     drumbeat.NewFromString(drumbeat.One32, "[kick]\tx...............x...............;\n[snare]\t........x...............x......."),
+		"repeated":  drumbeat.NewFromString(drumbeat.One16, "[kick]\tx.......x.......x.......x.......;\n[snare]\t....x.......x.......x.......x..."),
+	}
+	for name, patterns := range same {
+		if got := Fingerprint(patterns...); got != want {
+			t.Errorf("expected the %s beat to share the fingerprint", name)
+		}
+	}
+
+	different := map[string][]*drumbeat.Pattern{
+		"other instrument": drumbeat.NewFromString(drumbeat.One16, "[kick]\tx.......x.......;\n[clap]\t....x.......x..."),
+		"extra hit":        drumbeat.NewFromString(drumbeat.One16, "[kick]\tx.......x.x.....;\n[snare]\t....x.......x..."),
+		"kick only":        drumbeat.NewFromString(drumbeat.One16, "[kick]\tx.......x......."),
+	}
+	for name, patterns := range different {
+		if got := Fingerprint(patterns...); got == want {
+			t.Errorf("expected the %s beat to have a different fingerprint", name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package drumbeat
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/mattetti/audio"
+	"github.com/mattetti/audio/wav"
+)
+
+const (
+	// DefaultSampleRate is the sample rate used when rendering audio.
+	DefaultSampleRate = 44100
+	// chokeFadeFrames is the length of the fade applied to choked samples to
+	// avoid clicks.
+	chokeFadeFrames = 64
+)
+
+// ErrInvalidTempo is returned when rendering audio without a tempo.
+var ErrInvalidTempo = errors.New("the tempo needs to be greater than 0")
+
+// Sample is a mono audio sample triggered by a pattern.
+type Sample struct {
+	// Data are the sample frames, between -1 and 1.
+	Data []float64
+	// SampleRate of the data, the sample is played as is if it doesn't
+	// match the rendering sample rate.
+	SampleRate int
+}
+
+// LoadWAVSample decodes a wav file, multichannel files are mixed down to mono.
+func LoadWAVSample(r io.ReadSeeker) (*Sample, error) {
+	d := wav.NewDecoder(r)
+	buf, err := d.FullPCMBuffer()
+	if err != nil {
+		return nil, err
+	}
+	chans := buf.Format.NumChannels
+	if chans < 1 {
+		chans = 1
+	}
+	max := float64(audio.IntMaxSignedValue(int(d.BitDepth)))
+	if max == 0 {
+		return nil, errors.New("unsupported wav bit depth")
+	}
+	s := &Sample{SampleRate: buf.Format.SampleRate, Data: make([]float64, len(buf.Ints)/chans)}
+	for i := range s.Data {
+		var v float64
+		for c := 0; c < chans; c++ {
+			v += float64(buf.Ints[i*chans+c])
+		}
+		s.Data[i] = v / float64(chans) / max
+	}
+	return s, nil
+}
+
+// AudioOptions are the settings used to render patterns to audio.
+type AudioOptions struct {
+	// BPM is the tempo of the rendering.
+	BPM float64
+	// SampleRate of the rendering, defaults to DefaultSampleRate.
+	SampleRate int
+	// Samples maps the pattern keys to the samples they trigger.
+	Samples map[int]*Sample
+}
+
+// voice is a sample being played.
+type voice struct {
+	pattern int
+	group   int
+	start   int
+	end     int
+	gain    float64
+	sample  *Sample
+}
+
+// RenderAudio mixes the samples triggered by the patterns and returns the mono
+// frames. The rendering lasts the length of the longest pattern plus the tail
+// of the samples still playing. A hit from a pattern in a choke group cuts the
+// samples of the other patterns of the same group.
+func RenderAudio(opts AudioOptions, patterns ...*Pattern) ([]float64, error) {
+	if opts.BPM <= 0 {
+		return nil, ErrInvalidTempo
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = DefaultSampleRate
+	}
+
+	var length int
+	voices := []*voice{}
+	for n, p := range patterns {
+		if p == nil || p.PPQN == 0 {
+			continue
+		}
+		p.ReAlign()
+		framesPerTick := float64(opts.SampleRate) * 60 / (opts.BPM * float64(p.PPQN))
+		stepSize := p.StepSize()
+		if l := int(float64(uint64(len(p.Pulses))*stepSize) * framesPerTick); l > length {
+			length = l
+		}
+		sample := opts.Samples[p.Key]
+		if sample == nil || len(sample.Data) == 0 {
+			continue
+		}
+		for _, pulse := range p.Pulses {
+			if pulse == nil || pulse.Velocity == 0 {
+				continue
+			}
+			for _, h := range pulse.hitsAt(pulse.Start(), stepSize) {
+				start := int(float64(nudge(pulse.Start(), h.Offset)) * framesPerTick)
+				voices = append(voices, &voice{
+					pattern: n,
+					group:   p.ChokeGroup,
+					start:   start,
+					end:     start + len(sample.Data),
+					gain:    float64(h.Velocity) / 127,
+					sample:  sample,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(voices, func(i, j int) bool { return voices[i].start < voices[j].start })
+	for i, v := range voices {
+		if v.group == 0 {
+			continue
+		}
+		for _, prev := range voices[:i] {
+			if prev.group == v.group && prev.pattern != v.pattern &&
+				prev.start < v.start && prev.end > v.start {
+				prev.end = v.start
+			}
+		}
+	}
+
+	for _, v := range voices {
+		if v.end > length {
+			length = v.end
+		}
+	}
+	out := make([]float64, length)
+	for _, v := range voices {
+		for f := v.start; f < v.end && f < len(out); f++ {
+			gain := v.gain
+			if v.end < v.start+len(v.sample.Data) {
+				// choked, fade out
+				if left := v.end - f; left < chokeFadeFrames {
+					gain *= float64(left) / chokeFadeFrames
+				}
+			}
+			out[f] += v.sample.Data[f-v.start] * gain
+		}
+	}
+	for i, v := range out {
+		if v > 1 {
+			out[i] = 1
+		} else if v < -1 {
+			out[i] = -1
+		}
+	}
+	return out, nil
+}
+
+// SaveAsWAV renders the patterns to a 16 bit mono wav file.
+func SaveAsWAV(w io.WriteSeeker, opts AudioOptions, patterns ...*Pattern) error {
+	frames, err := RenderAudio(opts, patterns...)
+	if err != nil {
+		return err
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = DefaultSampleRate
+	}
+	max := float64(audio.IntMaxSignedValue(16))
+	data := make([]int, len(frames))
+	for i, f := range frames {
+		data[i] = int(f * max)
+	}
+	buf := audio.NewPCMIntBuffer(data, &audio.Format{NumChannels: 1, SampleRate: opts.SampleRate, BitDepth: 16})
+	e := wav.NewEncoder(w, opts.SampleRate, 16, 1, 1)
+	if err := e.Write(buf); err != nil {
+		return err
+	}
+	return e.Close()
+}
@@ -0,0 +1,110 @@
+package drumbeat
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testSample(frames int) *Sample {
+	s := &Sample{SampleRate: 1000, Data: make([]float64, frames)}
+	for i := range s.Data {
+		s.Data[i] = 0.5
+	}
+	return s
+}
+
+func TestRenderAudio(t *testing.T) {
+	// at 60 BPM and 96 PPQN, a 1/16th step is 250 frames at 1000Hz
+	opts := AudioOptions{BPM: 60, SampleRate: 1000}
+
+	t.Run("no tempo", func(t *testing.T) {
+		if _, err := RenderAudio(AudioOptions{}, NewFromString(One16, "x...")...); err != ErrInvalidTempo {
+			t.Fatalf("expected ErrInvalidTempo, got %v", err)
+		}
+	})
+
+	t.Run("hits positions", func(t *testing.T) {
+		patterns := NewFromString(One16, "{C1}x...x...x...x...")
+		patterns[0].Pulses[4].Velocity = 127
+		opts.Samples = map[int]*Sample{patterns[0].Key: testSample(100)}
+		frames, err := RenderAudio(opts, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 4000 {
+			t.Fatalf("expected a bar of 4000 frames, got %d", len(frames))
+		}
+		if frames[1000] != 0.5 || frames[1099] != 0.5 || frames[1100] != 0 {
+			t.Errorf("expected the second hit to play the full sample at 1000 frames")
+		}
+		if frames[500] != 0 {
+			t.Errorf("expected silence between hits")
+		}
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		patterns := NewFromString(One16, "{C1}...............x")
+		opts.Samples = map[int]*Sample{patterns[0].Key: testSample(1000)}
+		frames, err := RenderAudio(opts, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 3750+1000 {
+			t.Fatalf("expected the sample tail to be rendered, got %d frames", len(frames))
+		}
+	})
+
+	t.Run("choke groups", func(t *testing.T) {
+		patterns := NewFromString(One16, "[hihat open]{A#1}x...............;[hihat]{F#1}....x...........")
+		opts.Samples = map[int]*Sample{patterns[0].Key: testSample(2000), patterns[1].Key: testSample(100)}
+		frames, err := RenderAudio(opts, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames[1500] == 0 {
+			t.Fatalf("expected the open hihat to ring without choke groups")
+		}
+
+		patterns[0].ChokeGroup, patterns[1].ChokeGroup = 1, 1
+		frames, err = RenderAudio(opts, patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames[1500] != 0 {
+			t.Errorf("expected the open hihat to be choked by the closed hihat")
+		}
+		if frames[990] >= frames[900] {
+			t.Errorf("expected the choked sample to fade out")
+		}
+	})
+}
+
+func TestSaveAsWAV(t *testing.T) {
+	patterns := NewFromString(One16, "{C1}x...x...x...x...")
+	opts := AudioOptions{BPM: 120, SampleRate: 8000, Samples: map[int]*Sample{patterns[0].Key: testSample(200)}}
+	f, err := ioutil.TempFile("", "drumbeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := SaveAsWAV(f, opts, patterns...); err != nil {
+		t.Fatal(err)
+	}
+	f.Seek(0, io.SeekStart)
+	s, err := LoadWAVSample(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SampleRate != 8000 {
+		t.Errorf("expected a sample rate of 8000, got %d", s.SampleRate)
+	}
+	if len(s.Data) != 16000 {
+		t.Fatalf("expected 2 seconds of audio, got %d frames", len(s.Data))
+	}
+	if d := s.Data[4000] - 90.0/127*0.5; d > 0.001 || d < -0.001 {
+		t.Errorf("expected the second hit to be decoded, got %v", s.Data[4000])
+	}
+}
@@ -0,0 +1,263 @@
+package drumbeat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrPPQNMismatch is reported by Beat.Validate when a pattern doesn't use
+	// the PPQN of the beat.
+	ErrPPQNMismatch = errors.New("the pattern doesn't use the PPQN of the beat")
+	// ErrGridMismatch is reported by Beat.Validate when a pattern doesn't use
+	// the grid of the beat.
+	ErrGridMismatch = errors.New("the pattern doesn't use the grid of the beat")
+	// ErrInvalidResolution is reported by Beat.Validate when the steps of the
+	// grid can't be expressed in ticks.
+	ErrInvalidResolution = errors.New("the grid doesn't fit the PPQN")
+	// ErrDuplicateKey is reported by Beat.Validate when several patterns
+	// trigger the same MIDI key.
+	ErrDuplicateKey = errors.New("the key is used by another pattern")
+	// ErrInvalidTimeSignature is reported by Beat.Validate when the time
+	// signature is incomplete or its denominator isn't a power of 2.
+	ErrInvalidTimeSignature = errors.New("invalid time signature")
+)
+
+// BeatError is the error returned by Beat.Validate.
+type BeatError struct {
+	// Pattern is the index of the invalid pattern, -1 if the error is about
+	// the beat itself.
+	Pattern int
+	// Err is one of the errors reported by Beat.Validate.
+	Err error
+}
+
+func (e *BeatError) Error() string {
+	if e.Pattern < 0 {
+		return "invalid beat: " + e.Err.Error()
+	}
+	return fmt.Sprintf("invalid pattern %d: %v", e.Pattern, e.Err)
+}
+
+// Beat is a set of patterns played together. The patterns share the PPQN and
+// grid of the beat and point to its metadata, which holds the tempo and time
+// signature.
+type Beat struct {
+	Metadata
+	PPQN     uint16
+	Grid     GridRes
+	Patterns []*Pattern
+}
+
+// NewBeat returns a beat made of the patterns, the PPQN and grid are taken
+// from the first pattern and the metadata is copied from the first pattern
+// having some.
+func NewBeat(patterns ...*Pattern) *Beat {
+	b := &Beat{PPQN: DefaultPPQN, Grid: One16}
+	for _, p := range patterns {
+		if p != nil {
+			b.PPQN, b.Grid = p.PPQN, p.Grid
+			break
+		}
+	}
+	if m := beatMeta(patterns); m != nil {
+		b.Metadata = *m
+	}
+	b.Add(patterns...)
+	return b
+}
+
+// Add adds the patterns to the beat, nil patterns are skipped.
+func (b *Beat) Add(patterns ...*Pattern) {
+	for _, p := range patterns {
+		if p != nil {
+			b.Patterns = append(b.Patterns, p)
+		}
+	}
+	b.sync()
+}
+
+// Remove removes the pattern from the beat and returns false if the beat
+// didn't contain it.
+func (b *Beat) Remove(p *Pattern) bool {
+	for i, pat := range b.Patterns {
+		if pat == p {
+			b.Patterns = append(b.Patterns[:i], b.Patterns[i+1:]...)
+			p.Meta = nil
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveByName removes the first pattern having the name, ignoring the case,
+// and returns it or nil if there isn't any.
+func (b *Beat) RemoveByName(name string) *Pattern {
+	p := b.ByName(name)
+	if p != nil {
+		b.Remove(p)
+	}
+	return p
+}
+
+// RemoveByKey removes the first pattern triggering the MIDI key and returns it
+// or nil if there isn't any.
+func (b *Beat) RemoveByKey(key int) *Pattern {
+	p := b.ByKey(key)
+	if p != nil {
+		b.Remove(p)
+	}
+	return p
+}
+
+// ByName returns the first pattern having the name, ignoring the case, or
+// nil.
+func (b *Beat) ByName(name string) *Pattern {
+	for _, p := range b.Patterns {
+		if p != nil && strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ByKey returns the first pattern triggering the MIDI key or nil.
+func (b *Beat) ByKey(key int) *Pattern {
+	for _, p := range b.Patterns {
+		if p != nil && p.Key == key {
+			return p
+		}
+	}
+	return nil
+}
+
+// Validate checks that the patterns share the PPQN and grid of the beat and
+// trigger different keys, the patterns without key are ignored. As in the
+// rest of the package, key 0 (C-2) means no key so several patterns can use
+// it. It returns a *BeatError describing the first problem found.
+func (b *Beat) Validate() error {
+	if b.PPQN == 0 || b.Grid.StepsInBeat() == 0 || uint64(b.PPQN)%b.Grid.StepsInBeat() != 0 {
+		return &BeatError{Pattern: -1, Err: ErrInvalidResolution}
+	}
+	if ts := b.TimeSignature; ts != (TimeSignature{}) && (ts.Numerator == 0 || ts.Denominator == 0 || ts.Denominator&(ts.Denominator-1) != 0) {
+		return &BeatError{Pattern: -1, Err: ErrInvalidTimeSignature}
+	}
+	if b.BPM < 0 {
+		return &BeatError{Pattern: -1, Err: ErrInvalidTempo}
+	}
+	keys := map[int]bool{}
+	for i, p := range b.Patterns {
+		switch {
+		case p == nil:
+			continue
+		case p.PPQN != b.PPQN:
+			return &BeatError{Pattern: i, Err: ErrPPQNMismatch}
+		case p.Grid != b.Grid:
+			return &BeatError{Pattern: i, Err: ErrGridMismatch}
+		case p.Key != 0 && keys[p.Key]:
+			return &BeatError{Pattern: i, Err: ErrDuplicateKey}
+		}
+		keys[p.Key] = true
+	}
+	return nil
+}
+
+// sync makes the patterns point to the metadata of the beat, or to none if
+// it's empty.
+func (b *Beat) sync() {
+	meta := &b.Metadata
+	if len(meta.pairs()) == 0 {
+		meta = nil
+	}
+	setMeta(b.Patterns, meta)
+}
+
+// prepare validates the beat before passing its patterns to one of the
+// package functions.
+func (b *Beat) prepare() ([]*Pattern, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.sync()
+	return b.Patterns, nil
+}
+
+// String returns the beat in the text notation, see FormatString.
+func (b *Beat) String() string {
+	b.sync()
+	return FormatString(b.Patterns...)
+}
+
+// ToMIDI validates the beat and converts it to MIDI, see ToMIDI.
+func (b *Beat) ToMIDI(w io.WriteSeeker) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	return ToMIDI(w, patterns...)
+}
+
+// Serialize validates and serializes the beat, see WriteTo.
+func (b *Beat) Serialize(w io.Writer) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	return WriteTo(w, patterns...)
+}
+
+// WriteJSON validates and serializes the beat as JSON, see WriteJSON.
+func (b *Beat) WriteJSON(w io.Writer) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, patterns...)
+}
+
+// SaveAsPNG validates the beat and draws it, see SaveAsPNGWithOptions.
+func (b *Beat) SaveAsPNG(w io.Writer, opts PNGOptions) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	return SaveAsPNGWithOptions(w, patterns, opts)
+}
+
+// SaveAsGIF validates the beat and animates it, see SaveAsGIF. The tempo of
+// the beat is used if the options don't set one.
+func (b *Beat) SaveAsGIF(w io.Writer, opts GIFOptions) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	if opts.BPM == 0 {
+		opts.BPM = b.BPM
+	}
+	return SaveAsGIF(w, patterns, opts)
+}
+
+// SaveAsWAV validates the beat and renders it, see SaveAsWAV. The tempo of
+// the beat is used if the options don't set one.
+func (b *Beat) SaveAsWAV(w io.WriteSeeker, opts AudioOptions) error {
+	patterns, err := b.prepare()
+	if err != nil {
+		return err
+	}
+	if opts.BPM == 0 {
+		opts.BPM = b.BPM
+	}
+	return SaveAsWAV(w, opts, patterns...)
+}
+
+// Timeline validates the beat and returns its note events, see Timeline.
+func (b *Beat) Timeline() ([]NoteEvent, uint64, error) {
+	patterns, err := b.prepare()
+	if err != nil {
+		return nil, 0, err
+	}
+	events, length := Timeline(patterns...)
+	return events, length, nil
+}
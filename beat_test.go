@@ -0,0 +1,93 @@
+package drumbeat
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBeat(t *testing.T) {
+	b := NewBeat(NewFromString(One16, "@bpm=94\n[kick]\t{C1}\tx...x...;\n[snare]\t{D1}\t....x...")...)
+	if b.PPQN != DefaultPPQN || b.Grid != One16 || b.BPM != 94 || len(b.Patterns) != 2 {
+		t.Fatalf("unexpected beat %+v", b)
+	}
+	hat := NewFromString(One16, "[hihat]\t{F#1}\tx.x.x.x.")[0]
+	b.Add(hat, nil)
+	if len(b.Patterns) != 3 || hat.Meta != &b.Metadata {
+		t.Fatalf("expected the hihat to be added and to share the metadata")
+	}
+	if b.ByName("HiHat") != hat || b.ByKey(42) != hat || b.ByName("clap") != nil || b.ByKey(39) != nil {
+		t.Error("unexpected lookup results")
+	}
+	if !b.Remove(hat) || b.Remove(hat) || len(b.Patterns) != 2 || hat.Meta != nil {
+		t.Error("expected the hihat to be removed once")
+	}
+	b.Add(hat)
+	if b.RemoveByName("HIHAT") != hat || b.RemoveByName("hihat") != nil || hat.Meta != nil {
+		t.Error("expected the hihat to be removed by name once")
+	}
+	b.Add(hat)
+	if b.RemoveByKey(42) != hat || b.RemoveByKey(42) != nil || len(b.Patterns) != 2 {
+		t.Error("expected the hihat to be removed by key once")
+	}
+
+	b.Title = "Boom"
+	if got, want := b.String(), "@title=Boom\n@bpm=94\n[kick]\t{C1}\tx...x...;\n[snare]\t{D1}\t....x..."; got != want {
+		t.Errorf("expected\n%q\ngot\n%q", want, got)
+	}
+	buf := &bytes.Buffer{}
+	if err := b.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded := NewBeat(patterns...); decoded.Title != "Boom" || decoded.BPM != 94 || decoded.Validate() != nil {
+		t.Errorf("unexpected decoded beat %+v", decoded)
+	}
+}
+
+func TestBeatValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		edit        func(b *Beat)
+		wantErr     error
+		wantPattern int
+	}{
+		{name: "valid", edit: func(b *Beat) {}},
+		{name: "ppqn", edit: func(b *Beat) { b.Patterns[1].PPQN = 480 }, wantErr: ErrPPQNMismatch, wantPattern: 1},
+		{name: "grid", edit: func(b *Beat) { b.Patterns[2].Grid = One32 }, wantErr: ErrGridMismatch, wantPattern: 2},
+		{name: "resolution", edit: func(b *Beat) { b.PPQN, b.Grid = 100, One64 }, wantErr: ErrInvalidResolution, wantPattern: -1},
+		{name: "duplicate key", edit: func(b *Beat) { b.Patterns[2].Key = 36 }, wantErr: ErrDuplicateKey, wantPattern: 2},
+		{name: "unset keys", edit: func(b *Beat) {
+			for _, p := range b.Patterns {
+				p.Key = 0
+			}
+		}},
+		{name: "time signature", edit: func(b *Beat) { b.TimeSignature = TimeSignature{3, 6} }, wantErr: ErrInvalidTimeSignature, wantPattern: -1},
+		{name: "tempo", edit: func(b *Beat) { b.BPM = -1 }, wantErr: ErrInvalidTempo, wantPattern: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBeat(NewFromString(One16, "[kick]\t{C1}\tx...;\n[snare]\t{D1}\t....x;\n[hihat]\t{F#1}\tx.x.")...)
+			tt.edit(b)
+			err := b.Validate()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			beatErr, ok := err.(*BeatError)
+			if !ok {
+				t.Fatalf("expected a *BeatError, got %v", err)
+			}
+			if beatErr.Err != tt.wantErr || beatErr.Pattern != tt.wantPattern {
+				t.Errorf("expected %v on pattern %d, got %v", tt.wantErr, tt.wantPattern, beatErr)
+			}
+			if err := b.ToMIDI(nil); err == nil || err.Error() != beatErr.Error() {
+				t.Errorf("expected ToMIDI to fail validating, got %v", err)
+			}
+		})
+	}
+}
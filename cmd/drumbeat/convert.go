@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+var convertCmd = &command{
+	name:  "convert",
+	args:  "[input]",
+	short: "Convert patterns between the text, MIDI, gob and JSON formats.",
+}
+
+func init() {
+	convertCmd.run = runConvert
+}
+
+func runConvert(e *env, args []string) error {
+	var flags ioFlags
+	fs := newFlagSet(e, convertCmd)
+	flags.register(fs, true, "output format: "+strings.Join(patternFormats, ", ")+" (text by default)")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	format, err := flags.outputFormat(formatText, patternFormats)
+	if err != nil {
+		return err
+	}
+	patterns, _, err := flags.readPatterns(e, args)
+	if err != nil {
+		return err
+	}
+	return flags.writeOutput(e, func(w io.Writer) error {
+		return writePatterns(w, format, patterns)
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"strings"
+
+	"github.com/go-audio/midi"
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/drumbeat/generators"
+)
+
+var generateCmd = &command{
+	name:  "generate",
+	args:  "<euclidean|style>",
+	short: "Generate patterns using euclidean rhythms or a style preset.",
+}
+
+func init() {
+	generateCmd.run = runGenerate
+}
+
+func runGenerate(e *env, args []string) error {
+	var flags ioFlags
+	fs := newFlagSet(e, generateCmd)
+	flags.register(fs, false, "output format: "+strings.Join(patternFormats, ", ")+" (text by default)")
+	seed := fs.Int64("seed", 0, "seed of the random generator, the same seed generates the same patterns")
+	fill := fs.Int("fill", 0, "length in beats (1, 2 or 4) of a fill added at the end, 0 for no fill")
+	bars := fs.Int("bars", 0, "number of bars of the style, defaults to the length of the preset")
+	variation := fs.Float64("variation", 0, "probability (0 to 1) of the style variations to be played")
+	steps := fs.Int("steps", 32, "number of euclidean steps")
+	pulses := fs.Int("pulses", 0, "number of euclidean pulses, defaults to 1 + steps/8")
+	offset := fs.Int("offset", 0, "offset of the first euclidean kick")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errUsage("missing the generator: euclidean or one of the styles: %s", styleNames())
+	}
+	format, err := flags.outputFormat(formatText, patternFormats)
+	if err != nil {
+		return err
+	}
+	if *fill < 0 || *fill == 3 || *fill > 4 {
+		return errUsage("invalid fill length %d, use 1, 2 or 4", *fill)
+	}
+	rnd := rand.New(rand.NewSource(*seed))
+
+	var patterns []*drumbeat.Pattern
+	if name := strings.ToLower(args[0]); name == "euclidean" {
+		patterns = generateEuclidean(rnd, *steps, *pulses, *offset)
+	} else {
+		style := generators.Style(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+		patterns, err = generators.Generate(style, generators.StyleOptions{Bars: *bars, Variation: *variation, Seed: *seed})
+		if err == generators.ErrUnknownStyle {
+			return errUsage("unknown generator %q, use euclidean or one of the styles: %s", args[0], styleNames())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if *fill > 0 {
+		barSteps := int(patterns[0].Grid.StepsInBeat() * 4)
+		lastBar := (len(patterns[0].Pulses) - 1) / barSteps
+		err := generators.Fill(patterns, lastBar, generators.FillOptions{
+			Length:     generators.FillLength(*fill),
+			Density:    0.7,
+			Complexity: 0.5,
+			Seed:       rnd.Int63(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return flags.writeOutput(e, func(w io.Writer) error {
+		return writePatterns(w, format, patterns)
+	})
+}
+
+// generateEuclidean generates a kick, a snare and a hihat using euclidean
+// rhythms, the hihat is randomized.
+func generateEuclidean(rnd *rand.Rand, steps, pulses, offset int) []*drumbeat.Pattern {
+	if steps < 8 {
+		steps = 8
+	}
+	if pulses < 1 {
+		pulses = 1 + steps/8
+	}
+
+	// split in two to give it more swag
+	kickSeq := generators.Euclidean(pulses/2, steps/2)
+	// The second time, we through in an extra kick, for free
+	kickSeq = append(kickSeq, generators.Euclidean((pulses/2)+1, steps/2)...)
+	kick := drumbeat.NewFromString(drumbeat.One16, boolsToSeq(kickSeq))[0]
+	if offset != 0 {
+		kick.Offset(offset)
+	}
+	kick.Key = midi.KeyInt("C", 1)
+	kick.Name = "Kick"
+
+	snare := drumbeat.NewFromString(drumbeat.One16, boolsToSeq(generators.Euclidean((pulses/2)+1, steps)))[0]
+	snare.Offset(4)
+	snare.Key = midi.KeyInt("D", 1)
+	snare.Name = "Snare"
+
+	// add some randomness to those hats
+	chunkSize := 3
+	groupSize := steps / chunkSize
+	hatSeq := []bool{}
+	for i := 0; i < chunkSize; i++ {
+		hatPulses := (steps / chunkSize) / 2
+		if rnd.Intn(2) == 0 {
+			hatPulses++
+		}
+		hatSeq = append(hatSeq, generators.Euclidean(hatPulses, groupSize)...)
+	}
+	if leftOver := steps % chunkSize; leftOver > 0 {
+		hatSeq = append(hatSeq, hatSeq[len(hatSeq)-leftOver:]...)
+	}
+	hat := drumbeat.NewFromString(drumbeat.One16, boolsToSeq(hatSeq))[0]
+	hat.Key = midi.KeyInt("F#", 1)
+	hat.Name = "HiHat"
+
+	patterns := []*drumbeat.Pattern{kick, snare, hat}
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	return patterns
+}
+
+func boolsToSeq(bools []bool) string {
+	str := make([]byte, len(bools))
+	for i, b := range bools {
+		if b {
+			str[i] = 'x'
+		} else {
+			str[i] = '.'
+		}
+	}
+	return string(str)
+}
+
+func styleNames() string {
+	names := make([]string, len(generators.Styles))
+	for i, s := range generators.Styles {
+		names[i] = strings.Replace(string(s), " ", "-", -1)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/go-audio/midi"
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/drumbeat/analysis"
+)
+
+var inspectCmd = &command{
+	name:  "inspect",
+	args:  "[input]",
+	short: "Print the grid of the patterns and their details.",
+}
+
+func init() {
+	inspectCmd.run = runInspect
+}
+
+func runInspect(e *env, args []string) error {
+	var flags ioFlags
+	fs := newFlagSet(e, inspectCmd)
+	flags.register(fs, true, "")
+	style := fs.String("style", "ascii", "how to print the hits: ascii, shades or colors")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	opts := drumbeat.TermOptions{}
+	switch *style {
+	case "ascii":
+	case "shades":
+		opts.Style = drumbeat.TermShades
+	case "colors":
+		opts.Style = drumbeat.TermColors
+	default:
+		return errUsage("unknown style %q, use ascii, shades or colors", *style)
+	}
+	patterns, format, err := flags.readPatterns(e, args)
+	if err != nil {
+		return err
+	}
+	return flags.writeOutput(e, func(w io.Writer) error {
+		return inspect(w, format, patterns, opts)
+	})
+}
+
+// inspect prints the grid of the patterns followed by a summary.
+func inspect(w io.Writer, format string, patterns []*drumbeat.Pattern, opts drumbeat.TermOptions) error {
+	if err := drumbeat.WriteTerm(w, patterns, opts); err != nil {
+		return err
+	}
+	first := patterns[0]
+	_, length := drumbeat.Timeline(patterns...)
+	var bars float64
+	if first.PPQN > 0 {
+		bars = float64(length) / float64(first.PPQN) / 4
+	}
+	report := analysis.Analyze(patterns...)
+	fmt.Fprintf(w, "\nformat: %s, patterns: %d, grid: %s, ppqn: %d, bars: %g, backbeat: %.2f\n\n",
+		format, len(patterns), first.Grid, first.PPQN, bars, report.Backbeat)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tkey\tinstrument\thits\tchoke\tdensity\tsyncopation\twnbd\tentropy\tevenness")
+	printStats := func(st analysis.Stats) {
+		fmt.Fprintf(tw, "\t%.2f\t%g\t%.2f\t%.2f\t%.2f\n", st.Density, st.Syncopation, st.WNBD, st.Entropy, st.Evenness)
+	}
+	for i, p := range patterns {
+		inst := string(p.Instrument())
+		if inst == "" {
+			inst = "-"
+		}
+		choke := "-"
+		if p.ChokeGroup != 0 {
+			choke = fmt.Sprint(p.ChokeGroup)
+		}
+		fmt.Fprintf(tw, "%s\t%s (%d)\t%s\t%d\t%s", p.Name, midi.NoteToName(p.Key), p.Key, inst, p.ActivePulses(), choke)
+		printStats(report.Patterns[i])
+	}
+	fmt.Fprintf(tw, "all\t\t\t%d\t", report.Combined.Onsets)
+	printStats(report.Combined)
+	return tw.Flush()
+}
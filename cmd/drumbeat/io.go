@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/filebuffer"
+)
+
+// formats of the inputs and outputs.
+const (
+	formatText  = "text"
+	formatMIDI  = "midi"
+	formatGob   = "gob"
+	formatJSON  = "json"
+	formatPNG   = "png"
+	formatSVG   = "svg"
+	formatASCII = "ascii"
+	formatGIF   = "gif"
+	formatWAV   = "wav"
+)
+
+// patternFormats are the formats the patterns can be converted to.
+var patternFormats = []string{formatText, formatMIDI, formatGob, formatJSON}
+
+// inputFormats are the formats the patterns can be read from.
+var inputFormats = []string{formatText, formatMIDI, formatGob, formatJSON, formatPNG}
+
+var extFormats = map[string]string{
+	".txt":  formatText,
+	".drum": formatText,
+	".mid":  formatMIDI,
+	".midi": formatMIDI,
+	".gob":  formatGob,
+	".json": formatJSON,
+	".png":  formatPNG,
+	".svg":  formatSVG,
+	".gif":  formatGIF,
+	".wav":  formatWAV,
+}
+
+// ioFlags are the flags shared by the commands reading and writing patterns.
+type ioFlags struct {
+	from string
+	to   string
+	out  string
+	grid string
+}
+
+// register adds the input flags and, if toUsage isn't empty, the output
+// flags to the flag set.
+func (f *ioFlags) register(fs *flag.FlagSet, input bool, toUsage string) {
+	if input {
+		fs.StringVar(&f.from, "from", "", "input format: "+strings.Join(inputFormats, ", ")+" (guessed by default)")
+		fs.StringVar(&f.grid, "grid", "1/16", "grid resolution of the text input: 1/4, 1/8, 1/16, 1/32 or 1/64")
+	}
+	if toUsage != "" {
+		fs.StringVar(&f.to, "to", "", toUsage)
+		fs.StringVar(&f.out, "o", "-", "output file, - for stdout")
+	}
+}
+
+// outputFormat returns the format set using -to or the one matching the
+// extension of the output file, def is used otherwise.
+func (f *ioFlags) outputFormat(def string, valid []string) (string, error) {
+	format := f.to
+	if format == "" {
+		format = extFormats[strings.ToLower(filepath.Ext(f.out))]
+	}
+	if format == "" {
+		format = def
+	}
+	if !contains(valid, format) {
+		return "", errUsage("unsupported output format %q, use one of: %s", format, strings.Join(valid, ", "))
+	}
+	return format, nil
+}
+
+// readPatterns reads the patterns from the file at path, or stdin if the path
+// is empty or -. It returns the format of the input.
+func (f *ioFlags) readPatterns(e *env, args []string) ([]*drumbeat.Pattern, string, error) {
+	path := "-"
+	if len(args) > 0 {
+		path = args[0]
+	}
+	grid, err := parseGrid(f.grid)
+	if err != nil {
+		return nil, "", err
+	}
+	format := f.from
+	if format == "" && path != "-" {
+		format = extFormats[strings.ToLower(filepath.Ext(path))]
+	}
+	if format != "" && !contains(inputFormats, format) {
+		return nil, "", errUsage("unsupported input format %q, use one of: %s", format, strings.Join(inputFormats, ", "))
+	}
+
+	var data []byte
+	if path == "-" {
+		data, err = ioutil.ReadAll(e.stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "" {
+		format = sniffFormat(data)
+	}
+
+	var patterns []*drumbeat.Pattern
+	r := bytes.NewReader(data)
+	switch format {
+	case formatText:
+		str := strings.TrimSpace(string(data))
+		if str == "" {
+			return nil, format, fmt.Errorf("no patterns in the text input")
+		}
+		patterns = drumbeat.NewFromString(grid, str)
+	case formatMIDI:
+		patterns, err = drumbeat.FromMIDI(r)
+	case formatGob:
+		patterns, err = drumbeat.ReadFrom(r)
+	case formatJSON:
+		patterns, err = drumbeat.ReadJSON(r)
+	case formatPNG:
+		patterns, err = drumbeat.FromPNG(r)
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to read the %s input - %v", format, err)
+	}
+	if len(patterns) == 0 {
+		return nil, format, fmt.Errorf("no patterns in the %s input", format)
+	}
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	return patterns, format, nil
+}
+
+// sniffFormat guesses the format of the data.
+func sniffFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("MThd")):
+		return formatMIDI
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return formatPNG
+	case json.Valid(data):
+		return formatJSON
+	case isText(data):
+		return formatText
+	}
+	return formatGob
+}
+
+// isText returns true if the data is UTF-8 without control characters other
+// than whitespaces.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// writePatterns encodes the patterns using one of the pattern formats.
+func writePatterns(w io.Writer, format string, patterns []*drumbeat.Pattern) error {
+	switch format {
+	case formatText:
+		_, err := io.WriteString(w, drumbeat.FormatString(patterns...)+"\n")
+		return err
+	case formatMIDI:
+		return writeSeekable(w, func(ws io.WriteSeeker) error {
+			return drumbeat.ToMIDI(ws, patterns...)
+		})
+	case formatGob:
+		return drumbeat.WriteTo(w, patterns...)
+	case formatJSON:
+		return drumbeat.WriteJSON(w, patterns...)
+	}
+	return errUsage("unsupported output format %q", format)
+}
+
+// writeSeekable buffers the output of encoders needing to seek.
+func writeSeekable(w io.Writer, write func(ws io.WriteSeeker) error) error {
+	buf := filebuffer.New(nil)
+	if err := write(buf); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Buff.Bytes())
+	return err
+}
+
+// writeOutput buffers the output so nothing is written if the command fails,
+// then writes it to the -o file or stdout.
+func (f *ioFlags) writeOutput(e *env, write func(w io.Writer) error) error {
+	buf := &bytes.Buffer{}
+	if err := write(buf); err != nil {
+		return err
+	}
+	if f.out == "" || f.out == "-" {
+		_, err := buf.WriteTo(e.stdout)
+		return err
+	}
+	return ioutil.WriteFile(f.out, buf.Bytes(), 0644)
+}
+
+// parseGrid parses a grid resolution such as 1/16, the 1/ can be omitted.
+func parseGrid(s string) (drumbeat.GridRes, error) {
+	if !strings.HasPrefix(s, "1/") {
+		s = "1/" + s
+	}
+	grid := drumbeat.GridRes(s)
+	switch grid {
+	case drumbeat.One4, drumbeat.One8, drumbeat.One16, drumbeat.One32, drumbeat.One64:
+		return grid, nil
+	}
+	return "", errUsage("invalid grid %q, use 1/4, 1/8, 1/16, 1/32 or 1/64", s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
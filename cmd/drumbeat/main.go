@@ -0,0 +1,160 @@
+// Command drumbeat converts, renders, generates, inspects and transforms drum
+// patterns.
+//
+// Usage:
+//
+//	drumbeat <command> [flags] [input]
+//
+// The input is read from stdin when missing or set to -, and the output is
+// written to stdout unless -o is set. The formats are guessed from the file
+// extensions and the content of the input, use -from and -to to set them.
+//
+// The exit code is 0 on success, 1 when the command fails and 2 when it's
+// used incorrectly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a drumbeat subcommand.
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(env *env, args []string) error
+}
+
+var commands = []*command{
+	convertCmd,
+	renderCmd,
+	generateCmd,
+	inspectCmd,
+	transformCmd,
+}
+
+// env holds the standard streams used by the commands.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// usageError is returned when a command is used incorrectly.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// errUsage returns a usage error.
+func errUsage(format string, a ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+func main() {
+	os.Exit(run(&env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// run executes the command line and returns the exit code.
+func run(e *env, args []string) int {
+	if len(args) < 1 {
+		usage(e.stderr)
+		return 2
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(e.stdout)
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(e, args[1:])
+		switch err.(type) {
+		case nil:
+			return 0
+		case *flagError:
+			// already reported by the flag package
+			return 2
+		case *usageError:
+			fmt.Fprintf(e.stderr, "drumbeat %s: %v\n", name, err)
+			return 2
+		}
+		if err == flag.ErrHelp {
+			return 0
+		}
+		fmt.Fprintf(e.stderr, "drumbeat %s: %v\n", name, err)
+		return 1
+	}
+	fmt.Fprintf(e.stderr, "drumbeat: unknown command %q\n", name)
+	usage(e.stderr)
+	return 2
+}
+
+// flagError marks the errors returned when parsing the flags.
+type flagError struct {
+	err error
+}
+
+func (e *flagError) Error() string {
+	return e.err.Error()
+}
+
+// newFlagSet returns the flag set of a command, its usage lists the flags.
+func newFlagSet(e *env, cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: drumbeat %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags, which can be placed before or after the
+// positional arguments, and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string, maxArgs int) ([]string, error) {
+	positional := []string{}
+	for {
+		// everything after -- is positional
+		if len(args) > 0 && args[0] == "--" {
+			positional = append(positional, args[1:]...)
+			break
+		}
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, &flagError{err: err}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) > maxArgs {
+		return nil, errUsage("unexpected arguments: %s", strings.Join(positional[maxArgs:], " "))
+	}
+	return positional, nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: drumbeat <command> [flags] [input]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run drumbeat <command> -h for the flags of a command.")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func runCmd(stdin string, args ...string) (code int, stdout, stderr string) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	code = run(&env{stdin: strings.NewReader(stdin), stdout: out, stderr: errOut}, args)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	const beat = "[kick]\t{C1}\tx...x...;\n[snare]\t{D1}\t....x(r2)..."
+	tests := []struct {
+		name     string
+		stdin    string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "no command", wantCode: 2},
+		{name: "unknown command", args: []string{"nope"}, wantCode: 2},
+		{name: "help", args: []string{"help"}, wantCode: 0},
+		{name: "command help", args: []string{"convert", "-h"}, wantCode: 0},
+		{name: "unknown flag", args: []string{"convert", "-nope"}, wantCode: 2},
+		{name: "missing input", args: []string{"convert", "/does/not/exist.mid"}, wantCode: 1},
+		{name: "empty input", args: []string{"convert"}, wantCode: 1},
+		{name: "bad output format", stdin: beat, args: []string{"convert", "-to", "png"}, wantCode: 2},
+		{name: "convert text",
+			stdin:   beat,
+			args:    []string{"convert"},
+			wantOut: "[kick]\t{C1}\tx...x...........;\n[snare]\t{D1}\t....x(r2)...........\n",
+		},
+		{name: "text grid",
+			stdin:   "x...",
+			args:    []string{"convert", "-grid", "8"},
+			wantOut: "x.......\n",
+		},
+		{name: "rotate",
+			stdin:   "x...x...",
+			args:    []string{"transform", "-rotate", "-1"},
+			wantOut: "...x...........x\n",
+		},
+		{name: "swing as text", stdin: beat, args: []string{"transform", "-swing", "66"}, wantCode: 2},
+		{name: "render ascii",
+			stdin:   "[kick]\t{C1}\tx...",
+			args:    []string{"render"},
+			wantOut: "              |1   :    :    :    |\nkick C1    36 |x...:....:....:....|\n",
+		},
+		{name: "wav without samples", stdin: beat, args: []string{"render", "-to", "wav"}, wantCode: 2},
+		{name: "generate style",
+			args:     []string{"generate", "rock"},
+			wantCode: 0,
+		},
+		{name: "unknown style", args: []string{"generate", "polka"}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, errOut := runCmd(tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("expected exit code %d, got %d: %s", tt.wantCode, code, errOut)
+			}
+			if tt.wantOut != "" && out != tt.wantOut {
+				t.Errorf("expected\n%q\ngot\n%q", tt.wantOut, out)
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	const beat = "[kick]\t{C1}\tx...x...........;\n[snare]\t{D1}\t....x(flam)...........\n"
+	for _, format := range []string{"json", "gob"} {
+		t.Run(format, func(t *testing.T) {
+			code, encoded, errOut := runCmd(beat, "convert", "-to", format)
+			if code != 0 {
+				t.Fatalf("failed to convert to %s: %s", format, errOut)
+			}
+			code, out, errOut := runCmd(encoded, "convert")
+			if code != 0 {
+				t.Fatalf("failed to convert from %s: %s", format, errOut)
+			}
+			if out != beat {
+				t.Errorf("expected\n%q\ngot\n%q", beat, out)
+			}
+		})
+	}
+}
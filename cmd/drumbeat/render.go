@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	// sprite formats
+	_ "image/gif"
+	_ "image/png"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-audio/midi"
+	"github.com/mattetti/drumbeat"
+)
+
+var renderCmd = &command{
+	name:  "render",
+	args:  "[input]",
+	short: "Render patterns as an image, an animation, text or audio.",
+}
+
+// renderFormats are the formats the patterns can be rendered to.
+var renderFormats = []string{formatPNG, formatSVG, formatASCII, formatGIF, formatWAV}
+
+func init() {
+	renderCmd.run = runRender
+}
+
+func runRender(e *env, args []string) error {
+	var (
+		flags   ioFlags
+		sprites = pairsFlag{}
+		samples = pairsFlag{}
+	)
+	fs := newFlagSet(e, renderCmd)
+	flags.register(fs, true, "output format: "+strings.Join(renderFormats, ", ")+" (ascii by default)")
+	theme := fs.String("theme", "light", "colors of the png and gif images: light or dark")
+	style := fs.String("style", "ascii", "how the ascii output prints the hits: ascii, shades or colors")
+	bpm := fs.Float64("bpm", 0, "tempo of the gif and wav outputs, defaults to the tempo of the metadata or 120")
+	roll := fs.Bool("roll", false, "draw the png and svg images as a piano roll showing the exact timing of the notes")
+	scale := fs.Int("scale", 1, "pixel zoom of the png and gif images")
+	fs.Var(sprites, "sprite", "`instrument=file` image drawn on the hits of an instrument in the gif output, can be repeated")
+	fs.Var(samples, "sample", "`key=file` wav sample played by the patterns using a MIDI key (36 or C1) in the wav output, can be repeated")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	format, err := flags.outputFormat(formatASCII, renderFormats)
+	if err != nil {
+		return err
+	}
+	var th *drumbeat.Theme
+	switch *theme {
+	case "light":
+		th = &drumbeat.LightTheme
+	case "dark":
+		th = &drumbeat.DarkTheme
+	default:
+		return errUsage("unknown theme %q, use light or dark", *theme)
+	}
+	termOpts := drumbeat.TermOptions{}
+	switch *style {
+	case "ascii":
+	case "shades":
+		termOpts.Style = drumbeat.TermShades
+	case "colors":
+		termOpts.Style = drumbeat.TermColors
+	default:
+		return errUsage("unknown style %q, use ascii, shades or colors", *style)
+	}
+	if (format == formatGIF || format == formatWAV) && *bpm < 0 {
+		return errUsage("the tempo needs to be greater than 0")
+	}
+	if format == formatWAV && len(samples) == 0 {
+		return errUsage("the wav output needs at least one -sample")
+	}
+
+	patterns, _, err := flags.readPatterns(e, args)
+	if err != nil {
+		return err
+	}
+	if *bpm == 0 {
+		*bpm = 120
+		if meta := patterns[0].Meta; meta != nil && meta.BPM > 0 {
+			*bpm = meta.BPM
+		}
+	}
+	pngOpts := drumbeat.PNGOptions{Theme: th, Scale: *scale}
+	return flags.writeOutput(e, func(w io.Writer) error {
+		switch format {
+		case formatPNG:
+			if *roll {
+				return drumbeat.SaveAsPianoRollPNG(w, patterns, drumbeat.PianoRollOptions{Theme: th})
+			}
+			return drumbeat.SaveAsPNGWithOptions(w, patterns, pngOpts)
+		case formatSVG:
+			if *roll {
+				return drumbeat.SaveAsPianoRollSVG(w, patterns, drumbeat.PianoRollOptions{Theme: th})
+			}
+			return drumbeat.SaveAsSVG(w, patterns, drumbeat.SVGOptions{})
+		case formatGIF:
+			opts := drumbeat.GIFOptions{BPM: *bpm, PNGOptions: pngOpts, Sprites: map[drumbeat.Instrument]image.Image{}}
+			for name, path := range sprites {
+				inst := drumbeat.InstrumentFromName(name)
+				if inst == drumbeat.Unknown {
+					return errUsage("unknown instrument %q", name)
+				}
+				if opts.Sprites[inst], err = loadSprite(path); err != nil {
+					return err
+				}
+			}
+			return drumbeat.SaveAsGIF(w, patterns, opts)
+		case formatWAV:
+			opts := drumbeat.AudioOptions{BPM: *bpm, Samples: map[int]*drumbeat.Sample{}}
+			for k, path := range samples {
+				key, err := parseKey(k)
+				if err != nil {
+					return err
+				}
+				if opts.Samples[key], err = loadSample(path); err != nil {
+					return err
+				}
+			}
+			return writeSeekable(w, func(ws io.WriteSeeker) error {
+				return drumbeat.SaveAsWAV(ws, opts, patterns...)
+			})
+		}
+		return drumbeat.WriteTerm(w, patterns, termOpts)
+	})
+}
+
+// pairsFlag collects repeated key=value flags.
+type pairsFlag map[string]string
+
+func (p pairsFlag) String() string {
+	pairs := []string{}
+	for k, v := range p {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p pairsFlag) Set(s string) error {
+	idx := strings.IndexByte(s, '=')
+	if idx < 1 || idx == len(s)-1 {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	p[s[:idx]] = s[idx+1:]
+	return nil
+}
+
+// parseKey parses a MIDI key number or note name such as C1 or F#1.
+func parseKey(s string) (int, error) {
+	if key, err := strconv.Atoi(s); err == nil && key >= 0 && key <= 127 {
+		return key, nil
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] != '-' && (s[i] < '0' || s[i] > '9') {
+			continue
+		}
+		oct, err := strconv.Atoi(s[i:])
+		if err != nil {
+			break
+		}
+		key := midi.KeyInt(strings.ToUpper(s[:1])+s[1:i], oct)
+		if key < 0 || key > 127 || midi.NoteToName(key) != strings.ToUpper(s[:1])+s[1:] {
+			break
+		}
+		return key, nil
+	}
+	return 0, errUsage("invalid MIDI key %q", s)
+}
+
+func loadSprite(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the sprite %s - %v", path, err)
+	}
+	return img, nil
+}
+
+func loadSample(path string) (*drumbeat.Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := drumbeat.LoadWAVSample(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the sample %s - %v", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/mattetti/drumbeat"
+)
+
+var transformCmd = &command{
+	name:  "transform",
+	args:  "[input]",
+	short: "Rotate, swing or humanize patterns.",
+}
+
+func init() {
+	transformCmd.run = runTransform
+}
+
+func runTransform(e *env, args []string) error {
+	var flags ioFlags
+	fs := newFlagSet(e, transformCmd)
+	flags.register(fs, true, "output format: "+strings.Join(patternFormats, ", ")+" (the input format by default)")
+	rotate := fs.Int("rotate", 0, "number of steps the patterns are moved to the right, negative values move them to the left")
+	swing := fs.Float64("swing", 0, "swing amount in percent, from 50 (straight) to 75")
+	timing := fs.Int("humanize-timing", 0, "maximum random nudge in ticks")
+	velocity := fs.Int("humanize-velocity", 0, "maximum random velocity change")
+	seed := fs.Int64("seed", 0, "seed used to humanize the patterns")
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *swing != 0 && (*swing < 50 || *swing > 75) {
+		return errUsage("invalid swing %g, use a value between 50 and 75", *swing)
+	}
+	if *timing < 0 || *velocity < 0 {
+		return errUsage("the humanize values can't be negative")
+	}
+	patterns, inputFormat, err := flags.readPatterns(e, args)
+	if err != nil {
+		return err
+	}
+	if !contains(patternFormats, inputFormat) {
+		inputFormat = formatText
+	}
+	format, err := flags.outputFormat(inputFormat, patternFormats)
+	if err != nil {
+		return err
+	}
+
+	if format == formatText && (*swing != 0 || *timing > 0 || *velocity > 0) {
+		return errUsage("the text format can't store the swing or the humanization, use -to midi, gob or json")
+	}
+
+	if *rotate != 0 {
+		for _, p := range patterns {
+			if len(p.Pulses) > 0 {
+				p.Offset(*rotate % len(p.Pulses))
+			}
+		}
+	}
+	if *swing != 0 {
+		drumbeat.Swing(*swing, patterns...)
+	}
+	if *timing > 0 || *velocity > 0 {
+		drumbeat.Humanize(drumbeat.HumanizeOptions{Timing: *timing, Velocity: *velocity, Seed: *seed}, patterns...)
+	}
+	return flags.writeOutput(e, func(w io.Writer) error {
+		return writePatterns(w, format, patterns)
+	})
+}
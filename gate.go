@@ -0,0 +1,56 @@
+package drumbeat
+
+// GateMode defines how the length of the notes is computed.
+type GateMode uint8
+
+const (
+	// GateDefault uses the pattern's gate, or the pulse duration if the
+	// pattern doesn't have a gate.
+	GateDefault GateMode = iota
+	// GateTicks plays the notes for a fixed number of ticks.
+	GateTicks
+	// GatePercent plays the notes for a percentage of their duration.
+	GatePercent
+	// GateTrigger plays very short notes, for samplers in one shot mode.
+	GateTrigger
+)
+
+// Gate defines the length of the notes of a pattern or a pulse.
+type Gate struct {
+	Mode GateMode
+	// Value is the number of ticks or the percentage depending on the mode.
+	Value uint16
+}
+
+// Length returns the length in ticks of the pulse. The pulse's gate overrides
+// the pattern's gate which is used by default. Without a gate, the pulse is
+// played for its duration (or a step if it doesn't have one). Fixed and
+// trigger gates ignore the pulse duration.
+func (p *Pulse) Length(stepSize uint64, patternGate Gate) uint64 {
+	if p == nil {
+		return 0
+	}
+	duration := uint64(p.Duration)
+	if duration == 0 {
+		duration = stepSize
+	}
+	g := p.Gate
+	if g.Mode == GateDefault {
+		g = patternGate
+	}
+	var length uint64
+	switch g.Mode {
+	case GateTicks:
+		length = uint64(g.Value)
+	case GatePercent:
+		length = duration * uint64(g.Value) / 100
+	case GateTrigger:
+		length = stepSize / 8
+	default:
+		length = duration
+	}
+	if length < 1 {
+		length = 1
+	}
+	return length
+}
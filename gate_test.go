@@ -0,0 +1,111 @@
+package drumbeat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-audio/midi"
+	"github.com/mattetti/filebuffer"
+)
+
+func TestPulse_Length(t *testing.T) {
+	tests := []struct {
+		name        string
+		pulse       *Pulse
+		patternGate Gate
+		want        uint64
+	}{
+		{name: "no duration", pulse: &Pulse{}, want: 24},
+		{name: "duration", pulse: &Pulse{Duration: 72}, want: 72},
+		{name: "pattern ticks", pulse: &Pulse{Duration: 72}, patternGate: Gate{Mode: GateTicks, Value: 10}, want: 10},
+		{name: "pattern percent", pulse: &Pulse{Duration: 72}, patternGate: Gate{Mode: GatePercent, Value: 50}, want: 36},
+		{name: "pattern trigger", pulse: &Pulse{Duration: 24}, patternGate: Gate{Mode: GateTrigger}, want: 3},
+		{name: "pulse override",
+			pulse:       &Pulse{Duration: 24, Gate: Gate{Mode: GatePercent, Value: 200}},
+			patternGate: Gate{Mode: GateTrigger},
+			want:        48},
+		{name: "never empty", pulse: &Pulse{Duration: 24, Gate: Gate{Mode: GatePercent, Value: 1}}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pulse.Length(24, tt.patternGate); got != tt.want {
+				t.Errorf("Pulse.Length() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromString_gates(t *testing.T) {
+	p := NewFromString(One16, "(g50%)x===.x(trig)=x(g12).=x")[0]
+	if p.Gate != (Gate{Mode: GatePercent, Value: 50}) {
+		t.Errorf("expected the pattern to have a 50%% gate, got %+v", p.Gate)
+	}
+	if got := p.Pulses.String(); got != "x....x.x..x" {
+		t.Fatalf("expected the ties to not be pulses, got %s", got)
+	}
+	want := map[int]Pulse{
+		0:  {Duration: 96},
+		5:  {Duration: 48, Gate: Gate{Mode: GateTrigger}},
+		7:  {Duration: 24, Gate: Gate{Mode: GateTicks, Value: 12}},
+		10: {Duration: 24},
+	}
+	for i, w := range want {
+		if p.Pulses[i].Duration != w.Duration || p.Pulses[i].Gate != w.Gate {
+			t.Errorf("[%d] expected duration %d and gate %+v, got %d and %+v", i, w.Duration, w.Gate, p.Pulses[i].Duration, p.Pulses[i].Gate)
+		}
+	}
+}
+
+func TestNewFromString_underscoreRests(t *testing.T) {
+	p := NewFromString(One16, "x___x_..")[0]
+	if got := p.Pulses.String(); got != "x...x..." {
+		t.Fatalf("expected the underscores to be rests, got %s", got)
+	}
+	for _, i := range []int{0, 4} {
+		if d := p.Pulses[i].Duration; d != 24 {
+			t.Errorf("[%d] expected a one step duration, got %d", i, d)
+		}
+	}
+}
+
+func TestToMIDI_gates(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want string
+	}{
+		{name: "retriggers", str: "xx..", want: "on@0 off@24 on@24 off@48"},
+		{name: "tie", str: "x==.x...", want: "on@0 off@72 on@96 off@120"},
+		{name: "pattern trigger gate", str: "(trig)x.x.", want: "on@0 off@3 on@48 off@51"},
+		{name: "pulse gate", str: "(trig)x(g50%).x.", want: "on@0 off@12 on@48 off@51"},
+		{name: "gate longer than the next hit", str: "(g48)xx..", want: "on@0 off@24 on@24 off@72"},
+		{name: "ratchet with gate", str: "(trig)x(r2)...", want: "on@0 off@3 on@12 off@15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := filebuffer.New(nil)
+			if err := ToMIDI(buf, NewFromString(One16, tt.str)...); err != nil {
+				t.Fatal(err)
+			}
+			buf.Seek(0, io.SeekStart)
+			dec := midi.NewDecoder(buf)
+			if err := dec.Parse(); err != nil {
+				t.Fatal(err)
+			}
+			evs := []string{}
+			for _, ev := range dec.Tracks[0].Events {
+				switch ev.MsgType {
+				case midi.EventByteMap["NoteOn"]:
+					evs = append(evs, fmt.Sprintf("on@%d", ev.AbsTicks))
+				case midi.EventByteMap["NoteOff"]:
+					evs = append(evs, fmt.Sprintf("off@%d", ev.AbsTicks))
+				}
+			}
+			if got := strings.Join(evs, " "); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package generators
+
+// Euclidean returns a euclidean rhythm, the pulses being spread as evenly as
+// possible over the steps and the first step being played. E(3, 8) gives
+// x..x..x., the Cuban tresillo.
+func Euclidean(pulses, steps int) []bool {
+	if steps < 1 {
+		return nil
+	}
+	if pulses > steps {
+		pulses = steps
+	}
+	rhythm := make([]bool, steps)
+	if pulses < 1 {
+		return rhythm
+	}
+	for i := range rhythm {
+		rhythm[i] = i*pulses%steps < pulses
+	}
+	return rhythm
+}
@@ -0,0 +1,31 @@
+package generators
+
+import "testing"
+
+func TestEuclidean(t *testing.T) {
+	tests := []struct {
+		pulses, steps int
+		want          string
+	}{
+		{pulses: 3, steps: 8, want: "x..x..x."},
+		{pulses: 5, steps: 8, want: "x.x.xx.x"},
+		{pulses: 4, steps: 16, want: "x...x...x...x..."},
+		{pulses: 5, steps: 12, want: "x..x.x..x.x."},
+		{pulses: 0, steps: 4, want: "...."},
+		{pulses: 6, steps: 4, want: "xxxx"},
+		{pulses: 2, steps: 0, want: ""},
+	}
+	for _, tt := range tests {
+		var got []byte
+		for _, b := range Euclidean(tt.pulses, tt.steps) {
+			if b {
+				got = append(got, 'x')
+			} else {
+				got = append(got, '.')
+			}
+		}
+		if string(got) != tt.want {
+			t.Errorf("E(%d, %d): expected %s, got %s", tt.pulses, tt.steps, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package generators creates drum patterns algorithmically.
+package generators
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// ErrNoFillInstrument is returned when none of the patterns can be used to
+// play a fill (no snare, toms or a pattern with a known drum key).
+var ErrNoFillInstrument = errors.New("no snare or tom pattern to play the fill")
+
+// FillLength is the length of a fill in beats.
+type FillLength int
+
+const (
+	FillBeat    FillLength = 1
+	FillHalfBar FillLength = 2
+	FillBar     FillLength = 4
+)
+
+// FillOptions are the settings used to generate a fill.
+type FillOptions struct {
+	// Length of the fill, defaults to a beat.
+	Length FillLength
+	// Density is the probability (0 to 1) of a step being played.
+	Density float64
+	// Complexity (0 to 1) controls how much the fill moves between
+	// instruments and uses the finer steps of the grid.
+	Complexity float64
+	// Seed is used to make the fill generation reproducible.
+	Seed int64
+}
+
+// Fill writes a drum fill at the end of the bar at the lastBar index (0 based)
+// replacing the existing content of the snare, toms and cymbals during the
+// fill. When a crash pattern exists, it's hit on the step following the fill.
+// The patterns are extended if they are shorter than the last bar.
+func Fill(patterns []*drumbeat.Pattern, lastBar int, opts FillOptions) error {
+	if len(patterns) < 1 || patterns[0] == nil {
+		return nil
+	}
+	if opts.Length <= 0 {
+		opts.Length = FillBeat
+	}
+	if opts.Length > FillBar {
+		opts.Length = FillBar
+	}
+	if lastBar < 0 {
+		lastBar = 0
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+
+	var (
+		snare    *drumbeat.Pattern
+		kick     *drumbeat.Pattern
+		crash    *drumbeat.Pattern
+		toms     [3]*drumbeat.Pattern
+		muted    []*drumbeat.Pattern
+		fallback *drumbeat.Pattern
+	)
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		p.ReAlign()
+		switch inst := p.Instrument(); inst {
+		case drumbeat.Snare:
+			if snare == nil {
+				snare = p
+			}
+		case drumbeat.Kick:
+			if kick == nil {
+				kick = p
+			}
+		case drumbeat.Crash:
+			if crash == nil {
+				crash = p
+			}
+		case drumbeat.HighTom:
+			toms[0] = p
+		case drumbeat.MidTom:
+			toms[1] = p
+		case drumbeat.LowTom:
+			toms[2] = p
+		case drumbeat.ClosedHiHat, drumbeat.OpenHiHat, drumbeat.Ride, drumbeat.Clap, drumbeat.Rimshot:
+			muted = append(muted, p)
+		case drumbeat.Unknown:
+			if fallback == nil {
+				fallback = p
+			}
+		}
+	}
+
+	// voices are ordered from high to low so a simple fill rolls down the kit.
+	voices := []*drumbeat.Pattern{}
+	if snare != nil {
+		voices = append(voices, snare)
+	}
+	for _, t := range toms {
+		if t != nil {
+			voices = append(voices, t)
+		}
+	}
+	if len(voices) == 0 {
+		if fallback == nil {
+			return ErrNoFillInstrument
+		}
+		voices = append(voices, fallback)
+	}
+
+	ref := voices[0]
+	stepsInBeat := int(ref.Grid.StepsInBeat())
+	barSteps := stepsInBeat * 4
+	fillSteps := stepsInBeat * int(opts.Length)
+	end := (lastBar + 1) * barSteps
+	start := end - fillSteps
+	for _, p := range patterns {
+		if p != nil {
+			extend(p, end)
+		}
+	}
+
+	// clear the fill area
+	cleared := append(muted, voices...)
+	if crash != nil {
+		cleared = append(cleared, crash)
+	}
+	for _, p := range cleared {
+		for i := start; i < end; i++ {
+			p.Pulses[i] = nil
+		}
+	}
+
+	// simple fills are played on the 8th notes, complex ones use the full grid
+	// resolution.
+	stride := stepsInBeat / 2
+	if stride < 1 || opts.Complexity >= 0.5 {
+		stride = 1
+	}
+	density := math.Max(0.1, math.Min(1, opts.Density))
+	voice := 0
+	for i := start; i < end; i++ {
+		pos := i - start
+		// the first step of each beat is always played to anchor the fill
+		onBeat := pos%stepsInBeat == 0
+		if pos%stride != 0 && !onBeat {
+			continue
+		}
+		if !onBeat && rnd.Float64() > density {
+			continue
+		}
+
+		// move down the kit as the fill progresses, jumping around more when
+		// the fill is complex.
+		voice = (pos * len(voices)) / fillSteps
+		if rnd.Float64() < opts.Complexity {
+			voice = rnd.Intn(len(voices))
+		}
+		p := voices[voice]
+
+		// crescendo towards the end of the fill with some accents
+		vel := 70 + (50 * pos / fillSteps)
+		if onBeat {
+			vel += 10
+		} else if opts.Complexity > 0 {
+			vel -= rnd.Intn(int(20*opts.Complexity) + 1)
+		}
+		setPulse(p, i, vel)
+
+		// complex fills reinforce some of the hits with the kick
+		if kick != nil && onBeat && rnd.Float64() < opts.Complexity {
+			setPulse(kick, i, vel)
+		}
+	}
+
+	if crash != nil {
+		next := end
+		if next >= len(crash.Pulses) {
+			next = 0
+		}
+		setPulse(crash, next, 110)
+		if kick != nil && next < len(kick.Pulses) {
+			setPulse(kick, next, 110)
+		}
+	}
+	return nil
+}
+
+// extend adds empty steps so the pattern has at least n steps.
+func extend(p *drumbeat.Pattern, n int) {
+	if len(p.Pulses) < n {
+		p.Pulses = append(p.Pulses, make(drumbeat.Pulses, n-len(p.Pulses))...)
+	}
+}
+
+// setPulse sets a pulse at the passed step.
+func setPulse(p *drumbeat.Pattern, step, vel int) {
+	if vel > 127 {
+		vel = 127
+	}
+	if vel < 1 {
+		vel = 1
+	}
+	stepSize := p.StepSize()
+	p.Pulses[step] = &drumbeat.Pulse{
+		Ticks:    uint64(step) * stepSize,
+		Duration: uint16(stepSize),
+		Velocity: uint8(vel),
+	}
+}
@@ -0,0 +1,84 @@
+package generators
+
+import (
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  FillLength
+		lastBar int
+		// first step of the fill
+		start int
+	}{
+		{name: "beat", length: FillBeat, lastBar: 1, start: 28},
+		{name: "half bar", length: FillHalfBar, lastBar: 1, start: 24},
+		{name: "bar", length: FillBar, lastBar: 0, start: 0},
+		{name: "extending the patterns", length: FillBeat, lastBar: 3, start: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := drumbeat.NewFromString(drumbeat.One16, `
+				[kick]		{C1}	x.........x.....	x..x..x...x.....;
+				[snare]		{D1}	....x.......x...	....x.......x...;
+				[hihat]		{F#1}	x.x.x.x.x.x.x.x.	x.x.x.x.x.x.x.x.;
+				[high tom]	{D2}	................	................;
+				[low tom]	{A1}	................	................;
+				[crash]		{C#2}	................	................`)
+			opts := FillOptions{Length: tt.length, Density: 0.8, Complexity: 0.3, Seed: 42}
+			if err := Fill(patterns, tt.lastBar, opts); err != nil {
+				t.Fatal(err)
+			}
+			end := (tt.lastBar + 1) * 16
+			for _, p := range patterns {
+				if len(p.Pulses) < end {
+					t.Fatalf("%s should have at least %d steps, got %d", p.Name, end, len(p.Pulses))
+				}
+			}
+			snare, hat, highTom, lowTom, crash := patterns[1], patterns[2], patterns[3], patterns[4], patterns[5]
+			for i := tt.start; i < end; i++ {
+				if hat.Pulses[i] != nil {
+					t.Errorf("expected the hihat to be muted during the fill, got a hit at step %d", i)
+				}
+				if i%4 == 0 && snare.Pulses[i] == nil && highTom.Pulses[i] == nil && lowTom.Pulses[i] == nil {
+					t.Errorf("expected the fill to play on beat at step %d", i)
+				}
+			}
+			if tt.start > 0 && tt.start <= 32 && hat.Pulses[tt.start-2] == nil {
+				t.Errorf("expected the hihat to be kept before the fill")
+			}
+			next := end
+			if next >= len(crash.Pulses) {
+				next = 0
+			}
+			if crash.Pulses[next] == nil {
+				t.Errorf("expected a crash after the fill at step %d", next)
+			}
+
+			// the same seed generates the same fill
+			again := drumbeat.NewFromString(drumbeat.One16, `
+				[kick]		{C1}	x.........x.....	x..x..x...x.....;
+				[snare]		{D1}	....x.......x...	....x.......x...;
+				[hihat]		{F#1}	x.x.x.x.x.x.x.x.	x.x.x.x.x.x.x.x.;
+				[high tom]	{D2}	................	................;
+				[low tom]	{A1}	................	................;
+				[crash]		{C#2}	................	................`)
+			Fill(again, tt.lastBar, opts)
+			for i, p := range patterns {
+				if p.Pulses.String() != again[i].Pulses.String() {
+					t.Errorf("expected %s to be the same with the same seed:\n%s\n%s", p.Name, p.Pulses, again[i].Pulses)
+				}
+			}
+		})
+	}
+
+	t.Run("no fill instrument", func(t *testing.T) {
+		patterns := drumbeat.NewFromString(drumbeat.One16, "[kick] {C1} x...x...x...x...")
+		if err := Fill(patterns, 0, FillOptions{}); err != ErrNoFillInstrument {
+			t.Fatalf("expected ErrNoFillInstrument, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package generators
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// step states learned by the markov model.
+const (
+	rest = iota
+	hit
+	accent
+	nbrStates
+)
+
+// accentVelocity is the velocity from which a hit is considered accented.
+const accentVelocity = 100
+
+// Markov is a n-gram model learning, per instrument, which step state (rest,
+// hit or accent) follows the previous steps at a given position in the bar.
+// It can be trained on a corpus of patterns and sampled to create new ones.
+type Markov struct {
+	// Order is the number of previous steps used as context.
+	Order int
+	// Grid is the resolution the model is trained and sampled at, patterns
+	// with a different grid are ignored during training.
+	Grid drumbeat.GridRes
+	// Lanes are the learned instruments.
+	Lanes []*MarkovLane
+}
+
+// MarkovLane holds the statistics of an instrument.
+type MarkovLane struct {
+	// Name of the instrument as used in the generated patterns.
+	Name string
+	// Key is the MIDI key of the instrument.
+	Key int
+	// Patterns is the number of patterns the lane was trained with.
+	Patterns int
+	// Counts maps a context (position in the bar and previous states) to
+	// the number of times each state followed it.
+	Counts map[string][nbrStates]float64
+	// VelocitySums are the summed velocities of the hit and accent states.
+	VelocitySums [nbrStates]float64
+	// Hits is the number of time each state was seen.
+	Hits [nbrStates]float64
+}
+
+// NewMarkov returns an empty model using the passed order and grid.
+func NewMarkov(order int, grid drumbeat.GridRes) *Markov {
+	if order < 0 {
+		order = 0
+	}
+	return &Markov{Order: order, Grid: grid}
+}
+
+// LoadMarkov reads a model serialized with Save.
+func LoadMarkov(r io.Reader) (*Markov, error) {
+	m := &Markov{}
+	if err := gob.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save serializes the model to the writer.
+func (m *Markov) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(m)
+}
+
+// Train updates the model with the passed patterns. Patterns are matched to
+// lanes by instrument when it can be guessed, by name otherwise.
+func (m *Markov) Train(patterns ...*drumbeat.Pattern) {
+	for _, p := range patterns {
+		if p == nil || p.Grid != m.Grid {
+			continue
+		}
+		p.ReAlign()
+		if len(p.Pulses) == 0 {
+			continue
+		}
+		lane := m.lane(p)
+		lane.Patterns++
+		states := make([]int, len(p.Pulses))
+		for i, pulse := range p.Pulses {
+			states[i] = stateOf(pulse)
+			lane.Hits[states[i]]++
+			if states[i] != rest {
+				lane.VelocitySums[states[i]] += float64(pulse.Velocity)
+			}
+		}
+		barSteps := m.barSteps()
+		for i, st := range states {
+			// patterns are loops so the history wraps around
+			for order := 0; order <= m.Order; order++ {
+				hist := make([]int, order)
+				for j := 0; j < order; j++ {
+					idx := (i - order + j) % len(states)
+					if idx < 0 {
+						idx += len(states)
+					}
+					hist[j] = states[idx]
+				}
+				ctx := context(i%barSteps, hist)
+				counts := lane.Counts[ctx]
+				counts[st]++
+				lane.Counts[ctx] = counts
+			}
+		}
+	}
+}
+
+// TrainFromDir trains the model using all the MIDI (.mid/.midi), serialized
+// (.gob) and text (.txt) patterns found in the directory and its
+// sub-directories.
+func (m *Markov) TrainFromDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		var patterns []*drumbeat.Pattern
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".mid", ".midi":
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			patterns, err = drumbeat.FromMIDI(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s - %v", path, err)
+			}
+		case ".gob":
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			patterns, err = drumbeat.ReadFrom(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s - %v", path, err)
+			}
+		case ".txt":
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			patterns = drumbeat.NewFromString(m.Grid, string(data))
+		default:
+			return nil
+		}
+		m.Train(patterns...)
+		return nil
+	})
+}
+
+// Sample generates new patterns of the passed number of bars. The
+// temperature controls how adventurous the sampling is: 0 always picks the
+// most likely state, 1 follows the learned probabilities and greater values
+// flatten them.
+func (m *Markov) Sample(bars int, seed int64, temperature float64) []*drumbeat.Pattern {
+	if bars < 1 {
+		bars = 1
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	barSteps := m.barSteps()
+	nbrSteps := bars * barSteps
+	ppqn := drumbeat.DefaultPPQN
+	patterns := make([]*drumbeat.Pattern, 0, len(m.Lanes))
+	for _, lane := range m.Lanes {
+		p := &drumbeat.Pattern{
+			Name:   lane.Name,
+			Key:    lane.Key,
+			PPQN:   ppqn,
+			Grid:   m.Grid,
+			Pulses: make(drumbeat.Pulses, nbrSteps),
+		}
+		stepSize := p.StepSize()
+		states := make([]int, 0, nbrSteps)
+		for i := 0; i < nbrSteps; i++ {
+			st := m.next(lane, i%barSteps, states, rnd, temperature)
+			states = append(states, st)
+			if st == rest {
+				continue
+			}
+			vel := 90.0
+			if lane.Hits[st] > 0 {
+				vel = lane.VelocitySums[st] / lane.Hits[st]
+			}
+			p.Pulses[i] = &drumbeat.Pulse{
+				Ticks:    uint64(i) * stepSize,
+				Duration: uint16(stepSize),
+				Velocity: uint8(math.Min(127, math.Max(1, math.Floor(vel+0.5)))),
+			}
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// next picks the next state using the longest known context.
+func (m *Markov) next(lane *MarkovLane, pos int, states []int, rnd *rand.Rand, temperature float64) int {
+	var counts [nbrStates]float64
+	var found bool
+	for order := m.Order; order >= 0 && !found; order-- {
+		if order > len(states) {
+			continue
+		}
+		counts, found = lane.Counts[context(pos, states[len(states)-order:])]
+	}
+	if !found {
+		return rest
+	}
+
+	if temperature <= 0 {
+		best := rest
+		for st, c := range counts {
+			if c > counts[best] {
+				best = st
+			}
+		}
+		return best
+	}
+
+	var weights [nbrStates]float64
+	var total float64
+	for st, c := range counts {
+		if c > 0 {
+			weights[st] = math.Pow(c, 1/temperature)
+			total += weights[st]
+		}
+	}
+	x := rnd.Float64() * total
+	for st, w := range weights {
+		if x < w {
+			return st
+		}
+		x -= w
+	}
+	return rest
+}
+
+// lane returns the lane matching the pattern, creating it if needed.
+func (m *Markov) lane(p *drumbeat.Pattern) *MarkovLane {
+	name := p.Name
+	if inst := p.Instrument(); inst != drumbeat.Unknown {
+		name = string(inst)
+	}
+	for _, l := range m.Lanes {
+		if l.Name == name {
+			return l
+		}
+	}
+	l := &MarkovLane{Name: name, Key: p.Key, Counts: map[string][nbrStates]float64{}}
+	if k, ok := drumbeat.GMKeys[drumbeat.Instrument(name)]; ok && p.Key == 0 {
+		l.Key = k
+	}
+	m.Lanes = append(m.Lanes, l)
+	sort.Slice(m.Lanes, func(i, j int) bool { return m.Lanes[i].Name < m.Lanes[j].Name })
+	return l
+}
+
+func (m *Markov) barSteps() int {
+	return int(m.Grid.StepsInBeat() * 4)
+}
+
+func stateOf(p *drumbeat.Pulse) int {
+	switch {
+	case p == nil || p.Velocity == 0:
+		return rest
+	case p.Velocity >= accentVelocity:
+		return accent
+	}
+	return hit
+}
+
+// context builds the key of a position in the bar and previous states.
+func context(pos int, hist []int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d:", pos)
+	for _, st := range hist {
+		sb.WriteByte(byte('0' + st))
+	}
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+package generators
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestMarkov(t *testing.T) {
+	m := NewMarkov(2, drumbeat.One16)
+	m.Train(drumbeat.NewFromString(drumbeat.One16, `
+		[kick]	{C1}	x.......x.......;
+		[snare]	{D1}	....x.......x...;
+		[hihat]	{F#1}	x.x.x.x.x.x.x.x.`)...)
+	m.Train(drumbeat.NewFromString(drumbeat.One16, `
+		[kick]	{C1}	x.........x.....;
+		[snare]	{D1}	....x.......x...;
+		[hihat]	{F#1}	x.x.x.x.x.x.x.x.`)...)
+	// wrong grid, ignored
+	m.Train(drumbeat.NewFromString(drumbeat.One8, "[clap] {D#1} x.x.x.x.")...)
+	if len(m.Lanes) != 3 {
+		t.Fatalf("expected 3 lanes, got %d", len(m.Lanes))
+	}
+
+	t.Run("greedy sampling", func(t *testing.T) {
+		patterns := m.Sample(2, 1, 0)
+		want := map[string]string{
+			"snare":        "....x.......x.......x.......x...",
+			"closed hihat": "x.x.x.x.x.x.x.x.x.x.x.x.x.x.x.x.",
+		}
+		for _, p := range patterns {
+			if len(p.Pulses) != 32 {
+				t.Errorf("expected %s to have 32 steps, got %d", p.Name, len(p.Pulses))
+			}
+			if w, ok := want[p.Name]; ok && p.Pulses.String() != w {
+				t.Errorf("expected %s to be\n%s, got\n%s", p.Name, w, p.Pulses)
+			}
+		}
+	})
+
+	t.Run("seeded sampling", func(t *testing.T) {
+		a := m.Sample(4, 42, 1.5)
+		b := m.Sample(4, 42, 1.5)
+		for i := range a {
+			if a[i].Pulses.String() != b[i].Pulses.String() {
+				t.Errorf("expected the same seed to sample the same %s pattern", a[i].Name)
+			}
+			if a[i].Key == 0 {
+				t.Errorf("expected %s to have a key", a[i].Name)
+			}
+		}
+	})
+
+	t.Run("serialization", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := m.Save(buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadMarkov(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := m.Sample(2, 7, 1)
+		b := loaded.Sample(2, 7, 1)
+		if len(a) != len(b) {
+			t.Fatalf("expected %d patterns, got %d", len(a), len(b))
+		}
+		for i := range a {
+			if a[i].Pulses.String() != b[i].Pulses.String() {
+				t.Errorf("expected the loaded model to sample the same %s pattern", a[i].Name)
+			}
+		}
+	})
+}
+
+func TestMarkov_TrainFromDir(t *testing.T) {
+	m := NewMarkov(1, drumbeat.One16)
+	if err := m.TrainFromDir("../fixtures"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Lanes) == 0 {
+		t.Fatal("expected the fixtures to train some lanes")
+	}
+	if patterns := m.Sample(1, 1, 1); len(patterns) != len(m.Lanes) {
+		t.Fatalf("expected a pattern per lane, got %d", len(patterns))
+	}
+}
@@ -0,0 +1,250 @@
+package generators
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// ErrUnknownStyle is returned when generating a style that doesn't exist.
+var ErrUnknownStyle = errors.New("unknown style")
+
+// Style is a genre preset.
+type Style string
+
+const (
+	BoomBap     Style = "boom bap"
+	Trap        Style = "trap"
+	House       Style = "house"
+	Techno      Style = "techno"
+	DrumAndBass Style = "drum and bass"
+	Dubstep     Style = "dubstep"
+	Rock        Style = "rock"
+	Bossa       Style = "bossa"
+	Afrobeat    Style = "afrobeat"
+)
+
+// Styles lists the available presets.
+var Styles = []Style{BoomBap, Trap, House, Techno, DrumAndBass, Dubstep, Rock, Bossa, Afrobeat}
+
+// StyleOptions are the settings used to generate a style preset.
+type StyleOptions struct {
+	// Bars is the number of bars to generate, defaults to the length of the
+	// preset.
+	Bars int
+	// Variation (0 to 1) is the probability of the preset's variations to be
+	// played in each bar and the amount of velocity humanization.
+	Variation float64
+	// Seed is used to make the variations reproducible.
+	Seed int64
+}
+
+// preset is the definition of a style. The patterns and variations are
+// written using the NewFromString notation, the variations define, for each
+// pattern, the extra hits that can be added to a bar.
+type preset struct {
+	patterns   string
+	variations string
+}
+
+var presets = map[Style]preset{
+	BoomBap: {
+		patterns: `
+			[kick]			{C1}	x.x.......xx...x	x.x.....x......x;
+			[snare]			{D1}	....x.......x...	....x.......x...;
+			[hihat]			{F#1}	x.x.x.x.x.x.x.x.	x.x.x.x.x.x.x.x.`,
+		variations: `
+			......x.........;
+			.......x.......x;
+			...............x`,
+	},
+	Trap: {
+		patterns: `
+			[kick]			{C1}	x......x..x.....;
+			[snare]			{D1}	........x.......;
+			[clap]			{D#1}	........x.......;
+			[hihat]			{F#1}	x.x.x.x.x.x.x.x.;
+			[hihat open]	{A#1}	..............x.`,
+		variations: `
+			...x.....x...x..;
+			...............x;
+			................;
+			.x.x.....x.x.xx.;
+			......x.........`,
+	},
+	House: {
+		patterns: `
+			[kick]			{C1}	x...x...x...x...;
+			[clap]			{D#1}	....x.......x...;
+			[hihat]			{F#1}	.x.x.x.x.x.x.x.x;
+			[hihat open]	{A#1}	..x...x...x...x.`,
+		variations: `
+			...............x;
+			...............x;
+			x.......x.......;
+			................`,
+	},
+	Techno: {
+		patterns: `
+			[kick]			{C1}	x...x...x...x...;
+			[clap]			{D#1}	....x.......x...;
+			[rimshot]		{C#1}	...x..x.......x.;
+			[hihat]			{F#1}	.x.x.x.x.x.x.x.x;
+			[hihat open]	{A#1}	..x...x...x...x.`,
+		variations: `
+			...............x;
+			................;
+			..........x..x..;
+			x.......x.......;
+			................`,
+	},
+	DrumAndBass: {
+		patterns: `
+			[kick]			{C1}	x.........x.....	x.x.......x.....;
+			[snare]			{D1}	....x.......x...	....x.......x...;
+			[hihat]			{F#1}	x.x.x.x.x.x.x.x.	x.x.x.x.x.x.x.x.;
+			[ride]			{D#2}	..x...x...x...x.	..x...x...x...x.`,
+		variations: `
+			......x.........	.............x..;
+			.......x.......x	.......x.x.....x;
+			.x.....x.....x..	.x.....x.....x..;
+			................	................`,
+	},
+	Dubstep: {
+		patterns: `
+			[kick]			{C1}	x.........x.....	x..x..x...x.....;
+			[snare]			{D1}	........x.......	........x.......;
+			[hihat]			{F#1}	.xx...x....x..x.	.xx...x....x..x.;
+			[hihat open]	{A#1}	....x........x..	....x........x..`,
+		variations: `
+			...x............	.............x..;
+			...............x	..............xx;
+			x.......x.......	x.......x.......;
+			................	................`,
+	},
+	Rock: {
+		patterns: `
+			[kick]			{C1}	x.......x.x.....;
+			[snare]			{D1}	....x.......x...;
+			[hihat]			{F#1}	x.x.x.x.x.x.x.x.;
+			[crash]			{C#2}	................`,
+		variations: `
+			.......x......x.;
+			...............x;
+			................;
+			x...............`,
+	},
+	Bossa: {
+		patterns: `
+			[kick]			{C1}	x..xx..xx..xx..x	x..xx..xx..xx..x;
+			[rimshot]		{C#1}	x..x..x...x..x..	..x..x...x..x...;
+			[shaker]		{A#3}	xxxxxxxxxxxxxxxx	xxxxxxxxxxxxxxxx`,
+		variations: `
+			................	................;
+			.............x..	...............x;
+			................	................`,
+	},
+	Afrobeat: {
+		patterns: `
+			[kick]			{C1}	x.....x...x.....;
+			[snare]			{D1}	....x..x....x...;
+			[hihat]			{F#1}	x.x.x.x.x.x.x.x.;
+			[hihat open]	{A#1}	...x.......x....;
+			[cowbell]		{G#2}	x.x.x..x.x.x.x..;
+			[shaker]		{A#3}	xxxxxxxxxxxxxxxx`,
+		variations: `
+			.........x....x.;
+			..x.......x..x.x;
+			................;
+			................;
+			................;
+			................`,
+	},
+}
+
+// hihatChokeGroup is the choke group of the hihats in the presets.
+const hihatChokeGroup = 1
+
+const (
+	accentVel = 110
+	hitVel    = 90
+	softVel   = 70
+	ghostVel  = 50
+)
+
+// Generate returns the patterns of the style preset. The preset is repeated
+// to fill the requested number of bars, the variations and velocity
+// humanization are picked using the seed. The hihats share a choke group.
+func Generate(style Style, opts StyleOptions) ([]*drumbeat.Pattern, error) {
+	pre, ok := presets[Style(strings.ToLower(string(style)))]
+	if !ok {
+		return nil, ErrUnknownStyle
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	variation := math.Max(0, math.Min(1, opts.Variation))
+
+	patterns := drumbeat.NewFromString(drumbeat.One16, pre.patterns)
+	variations := drumbeat.NewFromString(drumbeat.One16, pre.variations)
+	presetSteps := len(patterns[0].Pulses)
+	barSteps := int(drumbeat.One16.StepsInBeat() * 4)
+	nbrSteps := presetSteps
+	if opts.Bars > 0 {
+		nbrSteps = opts.Bars * barSteps
+	}
+
+	for n, p := range patterns {
+		inst := p.Instrument()
+		if inst == drumbeat.ClosedHiHat || inst == drumbeat.OpenHiHat || inst == drumbeat.PedalHiHat {
+			p.ChokeGroup = hihatChokeGroup
+		}
+		stepSize := p.StepSize()
+		pulses := make(drumbeat.Pulses, nbrSteps)
+		for i := range pulses {
+			src := i % presetSteps
+			vel := 0
+			switch {
+			case p.Pulses[src] != nil:
+				vel = baseVelocity(inst, src)
+			case n < len(variations) && src < len(variations[n].Pulses) &&
+				variations[n].Pulses[src] != nil && rnd.Float64() < variation:
+				vel = ghostVel
+				if inst == drumbeat.Crash {
+					vel = accentVel
+				}
+			default:
+				continue
+			}
+			if variation > 0 {
+				vel += int(math.Floor((rnd.Float64()*2-1)*10*variation + 0.5))
+			}
+			pulses[i] = &drumbeat.Pulse{
+				Ticks:    uint64(i) * stepSize,
+				Duration: uint16(stepSize),
+				Velocity: uint8(math.Max(1, math.Min(127, float64(vel)))),
+			}
+		}
+		p.Pulses = pulses
+	}
+	return patterns, nil
+}
+
+// baseVelocity returns the velocity of an instrument at a given step so the
+// cymbals and percussions accent the beat.
+func baseVelocity(inst drumbeat.Instrument, step int) int {
+	switch inst {
+	case drumbeat.Snare, drumbeat.Clap, drumbeat.Crash:
+		return accentVel
+	case drumbeat.ClosedHiHat, drumbeat.Shaker, drumbeat.Ride, drumbeat.Cowbell:
+		switch {
+		case step%4 == 0:
+			return accentVel
+		case step%2 == 0:
+			return hitVel
+		}
+		return softVel
+	}
+	return hitVel
+}
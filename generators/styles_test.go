@@ -0,0 +1,63 @@
+package generators
+
+import (
+	"testing"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, style := range Styles {
+		t.Run(string(style), func(t *testing.T) {
+			patterns, err := Generate(style, StyleOptions{Bars: 4, Variation: 0.5, Seed: 12})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patterns) < 3 {
+				t.Fatalf("expected at least 3 patterns, got %d", len(patterns))
+			}
+			keys := map[int]bool{}
+			for _, p := range patterns {
+				if p.Grid != drumbeat.One16 {
+					t.Errorf("expected %s to be on a 1/16 grid, got %s", p.Name, p.Grid)
+				}
+				if len(p.Pulses) != 64 {
+					t.Errorf("expected %s to have 4 bars, got %d steps", p.Name, len(p.Pulses))
+				}
+				if p.Key == 0 || keys[p.Key] {
+					t.Errorf("expected %s to have its own key, got %d", p.Name, p.Key)
+				}
+				keys[p.Key] = true
+				if p.Instrument() == drumbeat.Unknown {
+					t.Errorf("expected %s to be a known instrument", p.Name)
+				}
+			}
+
+			again, _ := Generate(style, StyleOptions{Bars: 4, Variation: 0.5, Seed: 12})
+			for i, p := range patterns {
+				for j, pulse := range p.Pulses {
+					other := again[i].Pulses[j]
+					if (pulse == nil) != (other == nil) || (pulse != nil && pulse.Velocity != other.Velocity) {
+						t.Fatalf("expected %s to be the same with the same seed at step %d", p.Name, j)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("no variation", func(t *testing.T) {
+		patterns, err := Generate(BoomBap, StyleOptions{Seed: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := patterns[0].Pulses.String(); got != "x.x.......xx...xx.x.....x......x" {
+			t.Errorf("unexpected kick pattern %s", got)
+		}
+	})
+
+	t.Run("unknown style", func(t *testing.T) {
+		if _, err := Generate("polka", StyleOptions{}); err != ErrUnknownStyle {
+			t.Fatalf("expected ErrUnknownStyle, got %v", err)
+		}
+	})
+}
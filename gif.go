@@ -0,0 +1,133 @@
+package drumbeat
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+)
+
+// GIFOptions are the settings used to animate patterns as a GIF.
+type GIFOptions struct {
+	// BPM is the tempo of the animation, it needs to be greater than 0.
+	BPM float64
+	// PNGOptions set the look of the grid.
+	PNGOptions
+	// Sprites are drawn centered on the cells of the patterns played by an
+	// instrument when they are hit. The cells flash when their instrument
+	// doesn't have a sprite.
+	Sprites map[Instrument]image.Image
+	// LoopCount is the number of times the animation repeats, 0 loops
+	// forever and -1 plays it once.
+	LoopCount int
+}
+
+// flashColor is drawn over the cells being hit.
+var flashColor = color.NRGBA{255, 255, 255, 160}
+
+// SaveAsGIF animates the patterns as a looping GIF with one frame per step. A
+// playhead moves over the grid drawn like SaveAsPNG and the hits flash as they
+// are played.
+func SaveAsGIF(w io.Writer, patterns []*Pattern, opts GIFOptions) error {
+	if opts.BPM <= 0 {
+		return ErrInvalidTempo
+	}
+	base, l := renderPatterns(patterns, opts.PNGOptions)
+	if base == nil {
+		return nil
+	}
+	nbrSteps := len(patterns[0].Pulses)
+	stepsInBeat := float64(patterns[0].Grid.StepsInBeat())
+	if stepsInBeat <= 0 {
+		stepsInBeat = 1
+	}
+	// GIF delays are in 100ths of a second, the rounding errors are carried
+	// over so the animation doesn't drift from the tempo.
+	stepDelay := 60 / opts.BPM / stepsInBeat * 100
+	playhead := l.theme.LabelText
+	playhead.A = 64
+
+	frames := make([]*image.RGBA, nbrSteps)
+	for i := range frames {
+		frame := image.NewRGBA(base.Bounds())
+		draw.Draw(frame, frame.Bounds(), base, image.ZP, draw.Src)
+		for n := range patterns {
+			draw.Draw(frame, l.stepRect(i, n), image.NewUniform(playhead), image.ZP, draw.Over)
+		}
+		for n, pattern := range patterns {
+			if i >= len(pattern.Pulses) || pattern.Pulses[i] == nil || pattern.Pulses[i].Velocity == 0 {
+				continue
+			}
+			cell := l.stepRect(i, n)
+			sprite := opts.Sprites[pattern.Instrument()]
+			if sprite == nil {
+				draw.Draw(frame, cell, image.NewUniform(flashColor), image.ZP, draw.Over)
+				continue
+			}
+			center := cell.Min.Add(cell.Size().Div(2))
+			spriteSize := sprite.Bounds().Size()
+			r := image.Rectangle{Min: center.Sub(spriteSize.Div(2))}
+			r.Max = r.Min.Add(spriteSize)
+			draw.Draw(frame, r, sprite, sprite.Bounds().Min, draw.Over)
+		}
+		if opts.Scale > 1 {
+			frame = scaleImage(frame, opts.Scale)
+		}
+		frames[i] = frame
+	}
+
+	pal := gifPalette(l, frames)
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, nbrSteps),
+		Delay:     make([]int, nbrSteps),
+		LoopCount: opts.LoopCount,
+	}
+	for i, frame := range frames {
+		g.Image[i] = image.NewPaletted(frame.Bounds(), pal)
+		draw.Draw(g.Image[i], frame.Bounds(), frame, image.ZP, draw.Src)
+		g.Delay[i] = int(math.Round(float64(i+1)*stepDelay) - math.Round(float64(i)*stepDelay))
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// gifPalette returns the colors of the theme followed by the colors used in
+// the frames, the colors that don't fit are replaced by the closest ones.
+func gifPalette(l *pngLayout, frames []*image.RGBA) color.Palette {
+	pal := make(color.Palette, 0, 256)
+	seen := map[color.RGBA]bool{}
+	add := func(c color.Color) bool {
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		if !seen[rgba] && len(pal) < cap(pal) {
+			seen[rgba] = true
+			pal = append(pal, rgba)
+		}
+		return len(pal) < cap(pal)
+	}
+	t := l.theme
+	add(t.HitFill)
+	add(t.HitStroke)
+	add(t.LabelBackground)
+	add(t.LabelText)
+	for _, c := range l.opts.RowColors {
+		add(c)
+	}
+	for beat := range t.Backgrounds {
+		for row := range t.Backgrounds[beat] {
+			add(t.Backgrounds[beat][row])
+			add(t.GridStrokes[beat][row])
+		}
+	}
+	for _, frame := range frames {
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if !add(frame.RGBAAt(x, y)) {
+					return pal
+				}
+			}
+		}
+	}
+	return pal
+}
@@ -0,0 +1,80 @@
+package drumbeat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAsGIF(t *testing.T) {
+	newPatterns := func() []*Pattern {
+		return NewFromString(One16, `
+			[kick]	x...;
+			[snare]	....x...`)
+	}
+	red := color.NRGBA{255, 0, 0, 255}
+	sprite := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range sprite.Pix {
+		sprite.Pix[i] = []uint8{red.R, red.G, red.B, red.A}[i%4]
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		opts := GIFOptions{BPM: 96, Sprites: map[Instrument]image.Image{Snare: sprite}}
+		if err := SaveAsGIF(buf, newPatterns(), opts); err != nil {
+			t.Fatal(err)
+		}
+		g, err := gif.DecodeAll(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.Image) != 16 {
+			t.Fatalf("expected a frame per step, got %d", len(g.Image))
+		}
+		if size := g.Image[0].Bounds().Size(); size != image.Pt(460, 40) {
+			t.Errorf("expected the frames to have the size of the png, got %v", size)
+		}
+		// a 16th at 96 BPM lasts 15.625 100ths of a second
+		if want := []int{16, 15, 16, 16}; !reflect.DeepEqual(g.Delay[:4], want) {
+			t.Errorf("expected the delays to start with %v, got %v", want, g.Delay[:4])
+		}
+		var total int
+		for _, d := range g.Delay {
+			total += d
+		}
+		if total != 250 {
+			t.Errorf("expected the bar to last 2.5s, got %d", total)
+		}
+
+		at := func(frame int, pt image.Point) color.NRGBA {
+			return color.NRGBAModel.Convert(g.Image[frame].At(pt.X, pt.Y)).(color.NRGBA)
+		}
+		// the kick flashes on the first frame only
+		if got := at(0, image.Pt(150, 10)); got == LightTheme.HitFill {
+			t.Error("expected the kick to flash on the first frame")
+		}
+		if got := at(1, image.Pt(150, 10)); got != LightTheme.HitFill {
+			t.Errorf("expected the kick to be back to normal on the next frame, got %v", got)
+		}
+		// the playhead moves over the empty steps
+		if got := at(1, image.Pt(170, 30)); got == LightTheme.Backgrounds[0][1] {
+			t.Error("expected the playhead to cover the second step")
+		}
+		if got := at(0, image.Pt(170, 30)); got != LightTheme.Backgrounds[0][1] {
+			t.Errorf("expected the second step to be empty on the first frame, got %v", got)
+		}
+		// the snare uses its sprite
+		if got := at(4, image.Pt(230, 30)); got != red {
+			t.Errorf("expected the snare sprite to be drawn, got %v", got)
+		}
+	})
+
+	t.Run("invalid tempo", func(t *testing.T) {
+		if err := SaveAsGIF(&bytes.Buffer{}, newPatterns(), GIFOptions{}); err != ErrInvalidTempo {
+			t.Fatalf("expected ErrInvalidTempo, got %v", err)
+		}
+	})
+}
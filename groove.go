@@ -0,0 +1,133 @@
+package drumbeat
+
+import (
+	"io"
+	"math"
+)
+
+// Groove is a timing and velocity template covering a bar. It can be
+// extracted from a performance and applied to other patterns to copy its feel.
+type Groove struct {
+	// Grid is the resolution of the groove steps.
+	Grid GridRes
+	// Offsets are the timing deviations of each step expressed as a ratio of a
+	// step, negative values are played ahead of the grid.
+	Offsets []float64
+	// Velocities are the relative velocities of each step, 1 being the
+	// average velocity of the performance. 0 means that the step has no data.
+	Velocities []float64
+}
+
+// grooveHit is a hit position expressed in steps.
+type grooveHit struct {
+	pos float64
+	vel uint8
+}
+
+// GrooveFromMIDI extracts a groove from the unquantized notes of a MIDI file.
+// The offsets are measured against the passed grid resolution.
+func GrooveFromMIDI(r io.Reader, grid GridRes) (*Groove, error) {
+	absEvs, _, ppqn, _, err := midiEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	stepSize := float64(ppqn) / float64(grid.StepsInBeat())
+	hits := []grooveHit{}
+	for _, events := range absEvs {
+		for _, ev := range events {
+			hits = append(hits, grooveHit{pos: float64(ev.start) / stepSize, vel: ev.vel})
+		}
+	}
+	return newGroove(grid, hits), nil
+}
+
+// GrooveFromPatterns extracts a groove from the position and velocity of the
+// pulses of the passed patterns. The groove uses the grid of the first pattern.
+func GrooveFromPatterns(patterns ...*Pattern) *Groove {
+	if len(patterns) < 1 || patterns[0] == nil {
+		return nil
+	}
+	grid := patterns[0].Grid
+	hits := []grooveHit{}
+	for _, p := range patterns {
+		if p == nil || p.PPQN == 0 {
+			continue
+		}
+		for _, pulse := range p.Pulses {
+			if pulse == nil || pulse.Velocity == 0 {
+				continue
+			}
+			beats := (float64(pulse.Ticks) + float64(pulse.Nudge)) / float64(p.PPQN)
+			hits = append(hits, grooveHit{pos: beats * float64(grid.StepsInBeat()), vel: pulse.Velocity})
+		}
+	}
+	return newGroove(grid, hits)
+}
+
+// newGroove averages the hits per bar step.
+func newGroove(grid GridRes, hits []grooveHit) *Groove {
+	steps := int(grid.StepsInBeat() * 4)
+	g := &Groove{
+		Grid:       grid,
+		Offsets:    make([]float64, steps),
+		Velocities: make([]float64, steps),
+	}
+	counts := make([]int, steps)
+	var totalVel float64
+	for _, h := range hits {
+		closest := math.Floor(h.pos + 0.5)
+		// hits nudged before the first tick belong to the end of the bar
+		idx := (int(closest)%steps + steps) % steps
+		g.Offsets[idx] += h.pos - closest
+		g.Velocities[idx] += float64(h.vel)
+		totalVel += float64(h.vel)
+		counts[idx]++
+	}
+	if len(hits) == 0 {
+		return g
+	}
+	avgVel := totalVel / float64(len(hits))
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		g.Offsets[i] /= float64(c)
+		if avgVel > 0 {
+			g.Velocities[i] = (g.Velocities[i] / float64(c)) / avgVel
+		}
+	}
+	return g
+}
+
+// Apply nudges the pulses of the passed patterns and adjusts their velocities
+// to match the groove. The strength goes from 0 (no changes) to 1 (the
+// pattern plays exactly like the groove). The groove offsets are added to the
+// existing nudges, the pulses being kept within a step of their position.
+func (g *Groove) Apply(strength float64, patterns ...*Pattern) {
+	if g == nil || len(g.Offsets) == 0 || strength == 0 {
+		return
+	}
+	steps := len(g.Offsets)
+	stepsInBeat := float64(g.Grid.StepsInBeat())
+	for _, p := range patterns {
+		if p == nil || p.PPQN == 0 {
+			continue
+		}
+		stepSize := p.StepSize()
+		grooveStepSize := float64(p.PPQN) / stepsInBeat
+		for i, pulse := range p.Pulses {
+			if pulse == nil {
+				continue
+			}
+			beats := float64(uint64(i)*stepSize) / float64(p.PPQN)
+			idx := int(math.Floor(beats*stepsInBeat+0.5)) % steps
+			nudge := float64(pulse.Nudge) + math.Floor(strength*g.Offsets[idx]*grooveStepSize+0.5)
+			maxNudge := float64(stepSize) - 1
+			pulse.Nudge = int16(math.Max(-maxNudge, math.Min(maxNudge, nudge)))
+			if v := g.Velocities[idx]; v > 0 && pulse.Velocity > 0 {
+				vel := float64(pulse.Velocity) * (1 + strength*(v-1))
+				pulse.Velocity = uint8(math.Max(1, math.Min(127, math.Floor(vel+0.5))))
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package drumbeat
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestGrooveFromPatterns(t *testing.T) {
+	recorded := NewFromString(One16, "x.x.x.x.x.x.x.x.")[0]
+	for i, pulse := range recorded.Pulses {
+		if pulse == nil {
+			continue
+		}
+		// late offbeats and accented downbeats
+		if i%4 == 2 {
+			pulse.Nudge = 6
+			pulse.Velocity = 60
+		} else {
+			pulse.Velocity = 120
+		}
+	}
+	g := GrooveFromPatterns(recorded)
+	if len(g.Offsets) != 16 {
+		t.Fatalf("expected a groove of 16 steps, got %d", len(g.Offsets))
+	}
+	if g.Offsets[2] != 0.25 {
+		t.Errorf("expected step 2 to be a quarter step late, got %v", g.Offsets[2])
+	}
+	if g.Offsets[0] != 0 {
+		t.Errorf("expected step 0 to be on the grid, got %v", g.Offsets[0])
+	}
+	if g.Velocities[1] != 0 {
+		t.Errorf("expected step 1 to not have data, got %v", g.Velocities[1])
+	}
+
+	tests := []struct {
+		name     string
+		strength float64
+		nudge    int16
+		downVel  uint8
+		offVel   uint8
+	}{
+		{name: "no strength", strength: 0, nudge: 0, downVel: 90, offVel: 90},
+		{name: "half strength", strength: 0.5, nudge: 3, downVel: 105, offVel: 75},
+		{name: "full strength", strength: 1, nudge: 6, downVel: 120, offVel: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			programmed := NewFromString(One16, "x.x.x.x.x.x.x.x.x.x.x.x.x.x.x.x.")[0]
+			g.Apply(tt.strength, programmed)
+			for i, pulse := range programmed.Pulses {
+				if pulse == nil {
+					continue
+				}
+				wantNudge, wantVel := int16(0), tt.downVel
+				if i%4 == 2 {
+					wantNudge, wantVel = tt.nudge, tt.offVel
+				}
+				if pulse.Nudge != wantNudge || pulse.Velocity != wantVel {
+					t.Errorf("[%d] expected nudge %d and velocity %d, got %d and %d", i, wantNudge, wantVel, pulse.Nudge, pulse.Velocity)
+				}
+				if pulse.Ticks != uint64(i)*programmed.StepSize() {
+					t.Errorf("[%d] expected the pulse to stay on the grid, got %d ticks", i, pulse.Ticks)
+				}
+			}
+		})
+	}
+}
+
+func TestGrooveFromPatterns_earlyFirstHit(t *testing.T) {
+	p := NewFromString(One16, "x...")[0]
+	p.Pulses[0].Nudge = -20
+	g := GrooveFromPatterns(p)
+	if g.Velocities[15] == 0 || math.Abs(g.Offsets[15]-1.0/6) > 1e-9 {
+		t.Errorf("expected the hit to be a late last step, got offset %v", g.Offsets[15])
+	}
+}
+
+func TestGroove_Apply(t *testing.T) {
+	g := &Groove{Grid: One16, Offsets: []float64{0, 0.25, 2, -3}, Velocities: []float64{1, 1, 1, 1}}
+	tests := []struct {
+		name      string
+		strength  float64
+		wantNudge []int16
+	}{
+		{name: "no strength", strength: 0, wantNudge: []int16{-2, -2, -2, -2}},
+		{name: "added to the nudges", strength: 1, wantNudge: []int16{-2, 4, 23, -23}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewFromString(One16, "xxxx")[0]
+			for _, pulse := range p.Pulses {
+				pulse.Nudge = -2
+			}
+			g.Apply(tt.strength, p)
+			for i, want := range tt.wantNudge {
+				if got := p.Pulses[i].Nudge; got != want {
+					t.Errorf("[%d] expected nudge %d, got %d", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGrooveFromMIDI(t *testing.T) {
+	f, err := os.Open("fixtures/kickSnare.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := GrooveFromMIDI(f, One16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Offsets) != 16 {
+		t.Fatalf("expected a groove of 16 steps, got %d", len(g.Offsets))
+	}
+	var offGrid bool
+	for i, o := range g.Offsets {
+		if o < -0.5 || o > 0.5 {
+			t.Errorf("[%d] offset %v is further than half a step", i, o)
+		}
+		if o != 0 {
+			offGrid = true
+		}
+	}
+	if !offGrid {
+		t.Errorf("expected the unquantized performance to have offsets, got %v", g.Offsets)
+	}
+}
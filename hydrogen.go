@@ -0,0 +1,525 @@
+package drumbeat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// HydrogenPPQN is the resolution of the Hydrogen drum machine files, a 4/4
+// bar lasts 192 ticks.
+const HydrogenPPQN = 48
+
+// hydrogenLeadLag is the number of Hydrogen ticks a note is moved by a full
+// lead or lag.
+const hydrogenLeadLag = 5
+
+// HydrogenInstrument is an instrument of a Hydrogen song.
+type HydrogenInstrument struct {
+	ID   int
+	Name string
+	// Key is the MIDI output note of the instrument.
+	Key int
+}
+
+// HydrogenPattern is a Hydrogen pattern, its notes are converted to a beat
+// with a pattern per instrument.
+type HydrogenPattern struct {
+	Name     string
+	Category string
+	// Size is the length of the pattern in Hydrogen ticks, 192 for a 4/4
+	// bar. It is kept when exporting the pattern, the patterns of the beat
+	// being padded to full bars.
+	Size int
+	Beat *Beat
+}
+
+// HydrogenSong is a Hydrogen .h2song file. The name, author and tempo of the
+// song are stored in the metadata, along with its notes and license as extra
+// values.
+type HydrogenSong struct {
+	Metadata
+	Instruments []HydrogenInstrument
+	Patterns    []*HydrogenPattern
+	// Sequence lists the names of the patterns played together in each
+	// column of the song.
+	Sequence [][]string
+}
+
+// XML representation of the Hydrogen files.
+type (
+	h2Song struct {
+		XMLName         xml.Name       `xml:"song"`
+		Version         string         `xml:"version"`
+		BPM             float64        `xml:"bpm"`
+		Volume          float64        `xml:"volume"`
+		MetronomeVolume float64        `xml:"metronomeVolume"`
+		Name            string         `xml:"name"`
+		Author          string         `xml:"author"`
+		Notes           string         `xml:"notes"`
+		License         string         `xml:"license"`
+		LoopEnabled     bool           `xml:"loopEnabled"`
+		Mode            string         `xml:"mode"`
+		Instruments     []h2Instrument `xml:"instrumentList>instrument"`
+		Patterns        []h2Pattern    `xml:"patternList>pattern"`
+		Sequence        []h2Group      `xml:"patternSequence>group"`
+	}
+	h2Instrument struct {
+		ID             int     `xml:"id"`
+		Name           string  `xml:"name"`
+		Volume         float64 `xml:"volume"`
+		IsMuted        bool    `xml:"isMuted"`
+		PanL           float64 `xml:"pan_L"`
+		PanR           float64 `xml:"pan_R"`
+		MIDIOutChannel int     `xml:"midiOutChannel"`
+		MIDIOutNote    *int    `xml:"midiOutNote"`
+	}
+	h2Pattern struct {
+		Name string `xml:"name,omitempty"`
+		// the pattern files of Hydrogen 0.9 use pattern_name
+		PatternName string   `xml:"pattern_name,omitempty"`
+		Info        string   `xml:"info,omitempty"`
+		Category    string   `xml:"category"`
+		Size        int      `xml:"size"`
+		Notes       []h2Note `xml:"noteList>note"`
+	}
+	h2Note struct {
+		Position    int      `xml:"position"`
+		LeadLag     float64  `xml:"leadlag"`
+		Velocity    float64  `xml:"velocity"`
+		PanL        float64  `xml:"pan_L"`
+		PanR        float64  `xml:"pan_R"`
+		Pitch       float64  `xml:"pitch"`
+		Key         string   `xml:"key"`
+		Length      int      `xml:"length"`
+		Instrument  int      `xml:"instrument"`
+		NoteOff     bool     `xml:"note_off"`
+		Probability *float64 `xml:"probability"`
+	}
+	h2Group struct {
+		PatternIDs []string `xml:"patternID"`
+	}
+	h2DrumkitPattern struct {
+		XMLName     xml.Name  `xml:"drumkit_pattern"`
+		Xmlns       string    `xml:"xmlns,attr,omitempty"`
+		DrumkitName string    `xml:"drumkit_name"`
+		Pattern     h2Pattern `xml:"pattern"`
+	}
+)
+
+// ReadHydrogenSong decodes a Hydrogen .h2song file. The notes of each pattern
+// are mapped to the MIDI output notes of their instruments, the instruments
+// sharing a MIDI note are played by the same pattern.
+func ReadHydrogenSong(r io.Reader) (*HydrogenSong, error) {
+	var h2 h2Song
+	if err := xml.NewDecoder(r).Decode(&h2); err != nil {
+		return nil, err
+	}
+	s := &HydrogenSong{}
+	s.Title, s.Author, s.BPM = h2.Name, h2.Author, h2.BPM
+	if h2.Notes != "" {
+		s.set("notes", h2.Notes)
+	}
+	if h2.License != "" {
+		s.set("license", h2.License)
+	}
+	for _, inst := range h2.Instruments {
+		key := GMKeys[Kick] + inst.ID
+		if inst.MIDIOutNote != nil {
+			key = *inst.MIDIOutNote
+		}
+		s.Instruments = append(s.Instruments, HydrogenInstrument{ID: inst.ID, Name: inst.Name, Key: key})
+	}
+	for _, p := range h2.Patterns {
+		hp := newHydrogenPattern(p, s.Instruments)
+		hp.Beat.BPM = s.BPM
+		s.Patterns = append(s.Patterns, hp)
+	}
+	for _, g := range h2.Sequence {
+		s.Sequence = append(s.Sequence, g.PatternIDs)
+	}
+	return s, nil
+}
+
+// ReadHydrogenPattern decodes a Hydrogen pattern file. Pattern files don't
+// list the instruments of the drum kit, the instruments are mapped to the
+// MIDI notes Hydrogen uses by default, from C1 for the first instrument.
+func ReadHydrogenPattern(r io.Reader) (*HydrogenPattern, error) {
+	var h2 h2DrumkitPattern
+	if err := xml.NewDecoder(r).Decode(&h2); err != nil {
+		return nil, err
+	}
+	return newHydrogenPattern(h2.Pattern, nil), nil
+}
+
+// NewHydrogenPattern converts the beat to a Hydrogen pattern named after the
+// title of the beat.
+func NewHydrogenPattern(b *Beat) *HydrogenPattern {
+	hp := &HydrogenPattern{Name: b.Title, Category: "not_categorized", Beat: b}
+	for _, p := range b.Patterns {
+		if p == nil || p.PPQN == 0 {
+			continue
+		}
+		size := len(p.Pulses) * int(p.StepSize()) * HydrogenPPQN / int(p.PPQN)
+		if size > hp.Size {
+			hp.Size = size
+		}
+	}
+	return hp
+}
+
+// NewHydrogenSong returns a song playing the beats one after the other. The
+// instruments are made from the keys of the patterns and the tempo is the
+// one of the first beat.
+func NewHydrogenSong(beats ...*Beat) *HydrogenSong {
+	s := &HydrogenSong{}
+	names := map[string]bool{}
+	for i, b := range beats {
+		if b == nil {
+			continue
+		}
+		if s.BPM == 0 {
+			s.BPM = b.BPM
+		}
+		hp := NewHydrogenPattern(b)
+		if hp.Name == "" {
+			hp.Name = "Pattern " + strconv.Itoa(i+1)
+		}
+		// the sequence refers to the patterns by name
+		for base, n := hp.Name, 2; names[hp.Name]; n++ {
+			hp.Name = fmt.Sprintf("%s %d", base, n)
+		}
+		names[hp.Name] = true
+		s.Patterns = append(s.Patterns, hp)
+		s.Sequence = append(s.Sequence, []string{hp.Name})
+	}
+	s.Instruments = s.instruments()
+	return s
+}
+
+// instruments returns the instruments of the song completed by the keys of
+// the patterns which aren't mapped to any instrument.
+func (s *HydrogenSong) instruments() []HydrogenInstrument {
+	instruments := append([]HydrogenInstrument{}, s.Instruments...)
+	ids := map[int]bool{}
+	keys := map[int]bool{}
+	for _, inst := range instruments {
+		ids[inst.ID] = true
+		keys[inst.Key] = true
+	}
+	for _, hp := range s.Patterns {
+		if hp == nil || hp.Beat == nil {
+			continue
+		}
+		for _, p := range hp.Beat.Patterns {
+			if p == nil || keys[p.Key] {
+				continue
+			}
+			id := hydrogenID(p.Key)
+			for ids[id] {
+				id++
+			}
+			name := p.Name
+			if name == "" {
+				name = string(p.Instrument())
+			}
+			instruments = append(instruments, HydrogenInstrument{ID: id, Name: name, Key: p.Key})
+			ids[id], keys[p.Key] = true, true
+		}
+	}
+	sort.SliceStable(instruments, func(i, j int) bool { return instruments[i].ID < instruments[j].ID })
+	return instruments
+}
+
+// hydrogenID returns the instrument Hydrogen maps to the MIDI key by default.
+func hydrogenID(key int) int {
+	if id := key - GMKeys[Kick]; id >= 0 {
+		return id
+	}
+	return 0
+}
+
+// WriteHydrogenSong encodes the song as a Hydrogen .h2song file. The samples
+// of the instruments aren't part of the file, a drum kit needs to be loaded
+// in Hydrogen.
+func WriteHydrogenSong(w io.Writer, s *HydrogenSong) error {
+	h2 := h2Song{
+		Version:         "0.9.7",
+		BPM:             s.BPM,
+		Volume:          0.5,
+		MetronomeVolume: 0.5,
+		Name:            s.Title,
+		Author:          s.Author,
+		Notes:           s.Extra["notes"],
+		License:         s.Extra["license"],
+		Mode:            "song",
+	}
+	if h2.BPM <= 0 {
+		h2.BPM = 120
+	}
+	instruments := s.instruments()
+	ids := map[int]int{}
+	for _, inst := range instruments {
+		key := inst.Key
+		h2.Instruments = append(h2.Instruments, h2Instrument{
+			ID: inst.ID, Name: inst.Name, Volume: 1, PanL: 1, PanR: 1, MIDIOutChannel: -1, MIDIOutNote: &key,
+		})
+		if _, ok := ids[inst.Key]; !ok {
+			ids[inst.Key] = inst.ID
+		}
+	}
+	for _, hp := range s.Patterns {
+		if hp != nil {
+			h2.Patterns = append(h2.Patterns, hp.h2Pattern(ids))
+		}
+	}
+	for _, names := range s.Sequence {
+		h2.Sequence = append(h2.Sequence, h2Group{PatternIDs: names})
+	}
+	return writeXML(w, h2)
+}
+
+// WriteHydrogenPattern encodes the pattern as a Hydrogen pattern file for the
+// drum kit. The instruments of the kit are found from the keys of the
+// patterns, the first instrument being C1.
+func WriteHydrogenPattern(w io.Writer, hp *HydrogenPattern, drumkit string) error {
+	ids := map[int]int{}
+	if hp.Beat != nil {
+		for _, p := range hp.Beat.Patterns {
+			if p != nil {
+				ids[p.Key] = hydrogenID(p.Key)
+			}
+		}
+	}
+	p := hp.h2Pattern(ids)
+	p.PatternName = p.Name
+	return writeXML(w, h2DrumkitPattern{
+		Xmlns:       "http://www.hydrogen-music.org/drumkit_pattern",
+		DrumkitName: drumkit,
+		Pattern:     p,
+	})
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newHydrogenPattern converts the notes of a decoded pattern.
+func newHydrogenPattern(p h2Pattern, instruments []HydrogenInstrument) *HydrogenPattern {
+	hp := &HydrogenPattern{Name: p.Name, Category: p.Category, Size: p.Size}
+	if hp.Name == "" {
+		hp.Name = p.PatternName
+	}
+	hp.Beat = NewBeat(hydrogenLanes(p.Notes, p.Size, instruments)...)
+	hp.Beat.Title = hp.Name
+	hp.Beat.sync()
+	return hp
+}
+
+// hydrogenLanes converts Hydrogen notes to a pattern per MIDI note, sorted by
+// instrument id, the instruments sharing a note being merged. The grid is the
+// coarsest of 1/16, 1/32 and 1/64 matching the positions of the notes, the
+// notes out of the 1/16 grid are nudged otherwise. The lead and lag are
+// converted to nudges and the notes out of the pattern size are dropped.
+func hydrogenLanes(notes []h2Note, size int, instruments []HydrogenInstrument) []*Pattern {
+	grid := One16
+	for _, g := range []GridRes{One16, One32, One64} {
+		stepTicks := HydrogenPPQN / int(g.StepsInBeat())
+		aligned := true
+		for _, n := range notes {
+			aligned = aligned && n.Position%stepTicks == 0
+		}
+		if aligned {
+			grid = g
+			break
+		}
+	}
+	scale := int(DefaultPPQN) / HydrogenPPQN
+	stepSize := int(DefaultPPQN) / int(grid.StepsInBeat())
+	// the patterns are padded to full bars
+	barSteps := int(grid.StepsInBeat()) * 4
+	nbrSteps := (size*scale + stepSize - 1) / stepSize
+	nbrSteps = ((nbrSteps + barSteps - 1) / barSteps) * barSteps
+	if nbrSteps < barSteps {
+		nbrSteps = barSteps
+	}
+	limit := size * scale
+	if size <= 0 {
+		limit = nbrSteps * stepSize
+	}
+
+	// the instruments sharing a MIDI note are merged in the lane of the
+	// first one
+	lanes := map[int]*Pattern{}
+	firstID := map[int]int{}
+	for _, n := range notes {
+		vel := int(math.Round(n.Velocity * 127))
+		if n.NoteOff || vel <= 0 || (n.Probability != nil && *n.Probability <= 0) {
+			continue
+		}
+		if vel > 127 {
+			vel = 127
+		}
+		pos := n.Position * scale
+		if pos < 0 || pos >= limit {
+			continue
+		}
+		key, name := GMKeys[Kick]+n.Instrument, ""
+		for _, inst := range instruments {
+			if inst.ID == n.Instrument {
+				key, name = inst.Key, inst.Name
+				break
+			}
+		}
+		p := lanes[key]
+		if p == nil {
+			p = &Pattern{PPQN: DefaultPPQN, Grid: grid, Key: key, Name: name}
+			lanes[key] = p
+			firstID[key] = n.Instrument
+		}
+		if n.Instrument < firstID[key] {
+			p.Name = name
+			firstID[key] = n.Instrument
+		}
+		step := (pos + stepSize/2) / stepSize
+		// a note rounded past the last step is nudged from it
+		if step >= nbrSteps {
+			step = nbrSteps - 1
+		}
+		if len(p.Pulses) < nbrSteps {
+			p.Pulses = append(p.Pulses, make(Pulses, nbrSteps-len(p.Pulses))...)
+		}
+		if p.Pulses[step] != nil {
+			continue
+		}
+		pulse := &Pulse{
+			Ticks:    uint64(step * stepSize),
+			Duration: uint16(stepSize),
+			Velocity: uint8(vel),
+			Nudge:    int16(pos - step*stepSize + int(math.Round(n.LeadLag*hydrogenLeadLag*float64(scale)))),
+		}
+		if n.Probability != nil && *n.Probability < 1 {
+			pulse.Probability = uint8(math.Max(1, math.Round(*n.Probability*100)))
+		}
+		p.Pulses[step] = pulse
+	}
+
+	patterns := make([]*Pattern, 0, len(lanes))
+	for _, p := range lanes {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return firstID[patterns[i].Key] < firstID[patterns[j].Key] })
+	return patterns
+}
+
+// h2Pattern converts the pattern to Hydrogen notes, the ratchets and grace
+// notes are expanded and the conditions are lost. The nudges are converted to
+// lead and lag, or moved to the positions of the notes when too large.
+func (hp *HydrogenPattern) h2Pattern(ids map[int]int) h2Pattern {
+	p := h2Pattern{Name: hp.Name, Category: hp.Category, Size: hp.Size}
+	if p.Category == "" {
+		p.Category = "not_categorized"
+	}
+	if hp.Beat == nil {
+		return p
+	}
+	if p.Size <= 0 {
+		p.Size = NewHydrogenPattern(hp.Beat).Size
+	}
+	for _, pat := range hp.Beat.Patterns {
+		if pat == nil || pat.PPQN == 0 {
+			continue
+		}
+		stepSize := pat.StepSize()
+		for i, pulse := range pat.Pulses {
+			if pulse == nil || pulse.Velocity == 0 {
+				continue
+			}
+			probability := 1.0
+			if pulse.Probability > 0 && pulse.Probability < 100 {
+				probability = float64(pulse.Probability) / 100
+			}
+			// small nudges are kept as lead and lag so the notes stay on the grid
+			start := int64(uint64(i) * stepSize)
+			leadLag := float64(pulse.Nudge) * HydrogenPPQN / float64(pat.PPQN) / hydrogenLeadLag
+			if math.Abs(leadLag) > 1 {
+				start, leadLag = start+int64(pulse.Nudge), 0
+			}
+			for _, h := range pulse.Hits(stepSize) {
+				pos := int(math.Round(float64(start+h.Offset) * HydrogenPPQN / float64(pat.PPQN)))
+				if pos < 0 || pos >= p.Size {
+					continue
+				}
+				prob := probability
+				p.Notes = append(p.Notes, h2Note{
+					Position:    pos,
+					LeadLag:     leadLag,
+					Velocity:    float64(h.Velocity) / 127,
+					PanL:        0.5,
+					PanR:        0.5,
+					Key:         "C0",
+					Length:      -1,
+					Instrument:  ids[pat.Key],
+					Probability: &prob,
+				})
+			}
+		}
+	}
+	sort.SliceStable(p.Notes, func(i, j int) bool { return p.Notes[i].Position < p.Notes[j].Position })
+	return p
+}
+
+// Arrange plays the sequence of the song and returns a beat with a pattern
+// per instrument. The columns of the sequence last as long as their longest
+// pattern.
+func (s *HydrogenSong) Arrange() *Beat {
+	instruments := s.instruments()
+	ids := map[int]int{}
+	for _, inst := range instruments {
+		if _, ok := ids[inst.Key]; !ok {
+			ids[inst.Key] = inst.ID
+		}
+	}
+	byName := map[string]*HydrogenPattern{}
+	for _, hp := range s.Patterns {
+		if hp != nil {
+			byName[hp.Name] = hp
+		}
+	}
+	var notes []h2Note
+	var start int
+	for _, names := range s.Sequence {
+		var length int
+		for _, name := range names {
+			hp := byName[name]
+			if hp == nil {
+				continue
+			}
+			p := hp.h2Pattern(ids)
+			for _, n := range p.Notes {
+				n.Position += start
+				notes = append(notes, n)
+			}
+			if p.Size > length {
+				length = p.Size
+			}
+		}
+		start += length
+	}
+	b := NewBeat(hydrogenLanes(notes, start, instruments)...)
+	b.Metadata = s.Metadata
+	b.sync()
+	return b
+}
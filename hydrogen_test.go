@@ -0,0 +1,225 @@
+package drumbeat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const h2SongXML = `<?xml version="1.0" encoding="UTF-8"?>
+<song>
+ <version>0.9.7</version>
+ <bpm>96</bpm>
+ <name>Shuffle</name>
+ <author>Jane</author>
+ <notes>Some notes</notes>
+ <license>CC BY</license>
+ <instrumentList>
+  <instrument><id>0</id><name>Kick</name><midiOutNote>36</midiOutNote></instrument>
+  <instrument><id>2</id><name>Snare</name><midiOutNote>38</midiOutNote></instrument>
+  <instrument><id>6</id><name>Hat</name></instrument>
+ </instrumentList>
+ <patternList>
+  <pattern>
+   <name>groove</name>
+   <category>rock</category>
+   <size>192</size>
+   <noteList>
+    <note><position>0</position><leadlag>0</leadlag><velocity>1</velocity><instrument>0</instrument></note>
+    <note><position>96</position><leadlag>0</leadlag><velocity>0.8</velocity><instrument>0</instrument></note>
+    <note><position>48</position><leadlag>0.4</leadlag><velocity>0.8</velocity><instrument>2</instrument></note>
+    <note><position>144</position><leadlag>0</leadlag><velocity>0.8</velocity><instrument>2</instrument><probability>0.5</probability></note>
+   </noteList>
+  </pattern>
+  <pattern>
+   <name>hats</name>
+   <size>96</size>
+   <noteList>
+    <note><position>0</position><velocity>0.5</velocity><instrument>6</instrument></note>
+    <note><position>6</position><velocity>0.5</velocity><instrument>6</instrument></note>
+    <note><position>24</position><velocity>0</velocity><instrument>6</instrument></note>
+   </noteList>
+  </pattern>
+ </patternList>
+ <patternSequence>
+  <group><patternID>groove</patternID></group>
+  <group><patternID>groove</patternID><patternID>hats</patternID></group>
+ </patternSequence>
+</song>`
+
+func TestReadHydrogenSong(t *testing.T) {
+	s, err := ReadHydrogenSong(strings.NewReader(h2SongXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Shuffle" || s.Author != "Jane" || s.BPM != 96 || s.Extra["notes"] != "Some notes" || s.Extra["license"] != "CC BY" {
+		t.Errorf("unexpected metadata %+v", s.Metadata)
+	}
+	if len(s.Instruments) != 3 || s.Instruments[1] != (HydrogenInstrument{ID: 2, Name: "Snare", Key: 38}) || s.Instruments[2].Key != 42 {
+		t.Errorf("unexpected instruments %+v", s.Instruments)
+	}
+	if len(s.Patterns) != 2 || len(s.Sequence) != 2 || len(s.Sequence[1]) != 2 {
+		t.Fatalf("expected 2 patterns and 2 columns, got %d and %v", len(s.Patterns), s.Sequence)
+	}
+
+	groove := s.Patterns[0]
+	if groove.Name != "groove" || groove.Category != "rock" || groove.Size != 192 || groove.Beat.BPM != 96 {
+		t.Errorf("unexpected pattern %+v", groove)
+	}
+	if err := groove.Beat.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := "@title=groove\n@bpm=96\n[Kick]\t{C1}\tx.......x.......;\n[Snare]\t{D1}\t....x.......x(50%)..."
+	if got := groove.Beat.String(); got != want {
+		t.Errorf("expected\n%q\ngot\n%q", want, got)
+	}
+	snare := groove.Beat.ByKey(38)
+	if p := snare.Pulses[4]; p.Velocity != 102 || p.Nudge != 4 || p.Probability != 0 {
+		t.Errorf("unexpected snare pulse %+v", p)
+	}
+	if p := snare.Pulses[12]; p.Probability != 50 {
+		t.Errorf("expected a 50%% probability, got %d", p.Probability)
+	}
+
+	hats := s.Patterns[1].Beat
+	if hats.Grid != One32 || len(hats.Patterns) != 1 || hats.Patterns[0].Key != 42 || activeSteps(hats.Patterns[0].Pulses) != 2 {
+		t.Errorf("expected 2 hihat hits on a 1/32 grid, got %+v", hats.Patterns)
+	}
+	if l := len(hats.Patterns[0].Pulses); s.Patterns[1].Size != 96 || l != 32 {
+		t.Errorf("expected the half bar pattern to be padded to a bar, got %d ticks and %d steps", s.Patterns[1].Size, l)
+	}
+
+	b := s.Arrange()
+	if b.Title != "Shuffle" || b.Grid != One32 || len(b.Patterns) != 3 {
+		t.Fatalf("unexpected arrangement %+v", b)
+	}
+	if kick := b.ByKey(36); len(kick.Pulses) != 64 || activeSteps(kick.Pulses) != 4 {
+		t.Errorf("expected 4 kicks in 2 bars, got %s", kick.Pulses)
+	}
+	if hat := b.ByKey(42); hat.Pulses[32] == nil || hat.Pulses[33] == nil {
+		t.Errorf("expected the hihat to start the second bar, got %s", hat.Pulses)
+	}
+}
+
+func TestReadHydrogenSong_sharedKeys(t *testing.T) {
+	s, err := ReadHydrogenSong(strings.NewReader(`<song>
+ <bpm>120</bpm>
+ <instrumentList>
+  <instrument><id>0</id><name>Kick</name><midiOutNote>36</midiOutNote></instrument>
+  <instrument><id>1</id><name>Kick Layer</name><midiOutNote>36</midiOutNote></instrument>
+ </instrumentList>
+ <patternList>
+  <pattern>
+   <name>layers</name>
+   <size>192</size>
+   <noteList>
+    <note><position>96</position><velocity>1</velocity><instrument>1</instrument></note>
+    <note><position>0</position><velocity>1</velocity><instrument>0</instrument></note>
+    <note><position>0</position><velocity>1</velocity><instrument>1</instrument></note>
+   </noteList>
+  </pattern>
+ </patternList>
+</song>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.Patterns[0].Beat
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Patterns) != 1 || b.Patterns[0].Name != "Kick" || b.Patterns[0].Pulses.String() != "x.......x......." {
+		t.Errorf("expected the instruments to be merged in a kick pattern, got %s", b)
+	}
+	if err := WriteHydrogenSong(&bytes.Buffer{}, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadHydrogenSong_outOfRange(t *testing.T) {
+	s, err := ReadHydrogenSong(strings.NewReader(`<song>
+ <instrumentList>
+  <instrument><id>0</id><name>Kick</name><midiOutNote>36</midiOutNote></instrument>
+  <instrument><id>1</id><name>Snare</name><midiOutNote>38</midiOutNote></instrument>
+ </instrumentList>
+ <patternList>
+  <pattern>
+   <name>short</name>
+   <size>192</size>
+   <noteList>
+    <note><position>0</position><velocity>1</velocity><instrument>0</instrument></note>
+    <note><position>240</position><velocity>1</velocity><instrument>0</instrument></note>
+    <note><position>192</position><velocity>1</velocity><instrument>1</instrument></note>
+   </noteList>
+  </pattern>
+ </patternList>
+</song>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.Patterns[0].Beat
+	if len(b.Patterns) != 1 || b.Patterns[0].Pulses.String() != "x..............." {
+		t.Errorf("expected the notes past the pattern size to be dropped, got %s", b)
+	}
+}
+
+func TestHydrogenRoundTrip(t *testing.T) {
+	b := NewBeat(NewFromString(One16, "@title=Boom\n@bpm=94\n[kick]\t{C1}\tx...x...;\n[snare]\t{D1}\t....x...;\n[hihat]\t{F#1}\tx.x.x.x.")...)
+	b.ByName("snare").Pulses[4].Probability = 25
+	b.ByName("kick").Pulses[4].Nudge = -6
+
+	buf := &bytes.Buffer{}
+	if err := WriteHydrogenSong(buf, NewHydrogenSong(b, b)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadHydrogenSong(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.BPM != 94 || len(s.Instruments) != 3 || len(s.Patterns) != 2 || s.Patterns[1].Name != "Boom 2" {
+		t.Fatalf("unexpected song %+v", s)
+	}
+	for i, inst := range s.Instruments {
+		if inst.ID != inst.Key-36 {
+			t.Errorf("expected instrument %d to use the default Hydrogen id, got %+v", i, inst)
+		}
+	}
+	want := "@title=Boom\n@bpm=94\n[kick]\t{C1}\tx...x...........;\n[snare]\t{D1}\t....x(25%)...........;\n[hihat]\t{F#1}\tx.x.x.x........."
+	if got := s.Patterns[0].Beat.String(); got != want {
+		t.Errorf("expected\n%q\ngot\n%q", want, got)
+	}
+	if p := s.Patterns[0].Beat.ByKey(38).Pulses[4]; p.Probability != 25 {
+		t.Errorf("expected the probability to be kept, got %d", p.Probability)
+	}
+	if p := s.Patterns[0].Beat.ByKey(36).Pulses[4]; p.Nudge != -6 {
+		t.Errorf("expected the nudge to be kept, got %d", p.Nudge)
+	}
+	if a := s.Arrange(); len(a.ByKey(36).Pulses) != 16 || activeSteps(a.ByKey(42).Pulses) != 8 {
+		t.Errorf("expected the 2 half bars to be arranged in a bar, got %s", a)
+	}
+
+	buf.Reset()
+	if err := WriteHydrogenPattern(buf, NewHydrogenPattern(b), "GMRockKit"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<drumkit_name>GMRockKit</drumkit_name>") {
+		t.Errorf("expected the drum kit name in\n%s", buf)
+	}
+	hp, err := ReadHydrogenPattern(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "@title=Boom\n{C1}\tx...x...........;\n{D1}\t....x(25%)...........;\n{F#1}\tx.x.x.x........."
+	if got := hp.Beat.String(); hp.Name != "Boom" || hp.Size != 96 || got != want {
+		t.Errorf("expected %q of 96 ticks\n%q\ngot %q of %d ticks\n%q", "Boom", want, hp.Name, hp.Size, got)
+	}
+}
+
+func activeSteps(pulses Pulses) int {
+	var n int
+	for _, p := range pulses {
+		if p != nil && p.Velocity > 0 {
+			n++
+		}
+	}
+	return n
+}
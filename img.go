@@ -1,110 +1,471 @@
 package drumbeat
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
 	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
 
+	"github.com/go-audio/midi"
+	xdraw "golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/inconsolata"
 	"golang.org/x/image/math/fixed"
 )
 
+// Theme are the colors used to draw the patterns.
+type Theme struct {
+	HitFill         color.NRGBA
+	HitStroke       color.NRGBA
+	LabelBackground color.NRGBA
+	LabelText       color.NRGBA
+	// Backgrounds and GridStrokes of the steps are indexed by beat then by
+	// row, so they alternate every other beat and pattern.
+	Backgrounds [2][2]color.NRGBA
+	GridStrokes [2][2]color.NRGBA
+}
+
+// LightTheme is the default gray theme.
+var LightTheme = Theme{
+	HitFill:         color.NRGBA{124, 178, 227, 255},
+	HitStroke:       color.NRGBA{30, 30, 30, 255},
+	LabelBackground: color.NRGBA{135, 135, 135, 255},
+	LabelText:       color.NRGBA{0, 0, 0, 255},
+	Backgrounds: [2][2]color.NRGBA{
+		{{165, 165, 165, 255}, {149, 149, 149, 255}},
+		{{158, 158, 158, 255}, {143, 143, 143, 255}},
+	},
+	GridStrokes: [2][2]color.NRGBA{
+		{{153, 153, 153, 255}, {133, 133, 133, 255}},
+		{{147, 147, 147, 255}, {138, 138, 138, 255}},
+	},
+}
+
+// DarkTheme is a theme for dark backgrounds.
+var DarkTheme = Theme{
+	HitFill:         color.NRGBA{86, 156, 214, 255},
+	HitStroke:       color.NRGBA{15, 15, 15, 255},
+	LabelBackground: color.NRGBA{40, 40, 40, 255},
+	LabelText:       color.NRGBA{220, 220, 220, 255},
+	Backgrounds: [2][2]color.NRGBA{
+		{{58, 58, 58, 255}, {50, 50, 50, 255}},
+		{{54, 54, 54, 255}, {46, 46, 46, 255}},
+	},
+	GridStrokes: [2][2]color.NRGBA{
+		{{70, 70, 70, 255}, {64, 64, 64, 255}},
+		{{66, 66, 66, 255}, {60, 60, 60, 255}},
+	},
+}
+
+// PNGOptions are the settings used to draw patterns as PNG, the zero value
+// draws the default look.
+type PNGOptions struct {
+	// Theme defaults to LightTheme.
+	Theme *Theme
+	// StepWidth and StepHeight are the size of a step in pixels, default
+	// to 20.
+	StepWidth  int
+	StepHeight int
+	// LabelWidth defaults to 7 steps.
+	LabelWidth int
+	// Scale multiplies the size of the image for high-DPI screens. It is a
+	// pixel zoom: the image is drawn at its normal size then each pixel is
+	// enlarged, so the labels and strokes aren't drawn any sharper.
+	Scale int
+	// VelocityFill maps the velocity of the hits to the opacity of their
+	// fill (0 to 1), the hits are fully filled if nil. See VelocityOpacity.
+	VelocityFill func(velocity uint8) float64
+	// RowColors replace the theme's hit fill of each pattern, they are
+	// reused when there are more patterns than colors.
+	RowColors []color.NRGBA
+	// HideLabels removes the pattern names.
+	HideLabels bool
+	// ShowKeys adds a column with the MIDI key of the patterns.
+	ShowKeys bool
+	// Ruler adds a header numbering the bars and beats.
+	Ruler bool
+	// BarsPerLine wraps long patterns into stacked systems of this many
+	// bars, the patterns aren't wrapped if 0.
+	BarsPerLine int
+}
+
+// VelocityOpacity maps a velocity to an opacity so soft hits stay visible.
+func VelocityOpacity(vel uint8) float64 {
+	if vel > 127 {
+		vel = 127
+	}
+	return 0.25 + 0.75*float64(vel)/127
+}
+
 // SaveAsPNG converts the patterns into an image.
 func SaveAsPNG(w io.Writer, patterns []*Pattern) error {
-	if len(patterns) < 1 {
+	return SaveAsPNGWithOptions(w, patterns, PNGOptions{})
+}
+
+// SaveAsPNGWithOptions converts the patterns into an image using the passed
+// colors and layout.
+func SaveAsPNGWithOptions(w io.Writer, patterns []*Pattern, opts PNGOptions) error {
+	img := drawPatterns(patterns, opts)
+	if img == nil {
+		return nil
+	}
+	meta := beatMeta(patterns)
+	if meta == nil {
+		return png.Encode(w, img)
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return err
+	}
+	data, err := insertPNGText(buf.Bytes(), meta)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// pngKeywords are the predefined PNG keywords used for the metadata.
+var pngKeywords = map[string]string{
+	metaTitle:  "Title",
+	metaAuthor: "Author",
+	metaSource: "Source",
+}
+
+// insertPNGText adds the metadata as text chunks after the header of an
+// encoded PNG. The values which can't be encoded in Latin-1 use
+// international text chunks and the invalid keywords are skipped.
+func insertPNGText(data []byte, meta *Metadata) ([]byte, error) {
+	// signature and IHDR chunk
+	const headerEnd = 8 + 8 + 13 + 4
+	if len(data) < headerEnd || string(data[12:16]) != "IHDR" {
+		return nil, errors.New("unexpected PNG encoding")
+	}
+	chunks := &bytes.Buffer{}
+	for _, pair := range meta.pairs() {
+		key := pair.key
+		if k, ok := pngKeywords[key]; ok {
+			key = k
+		}
+		latinKey, ok := latin1(key)
+		if !ok || len(latinKey) < 1 || len(latinKey) > 79 {
+			continue
+		}
+		if value, ok := latin1(pair.value); ok {
+			writePNGChunk(chunks, "tEXt", append(append(latinKey, 0), value...))
+			continue
+		}
+		// no compression, language or translated keyword
+		chunk := append(latinKey, 0, 0, 0, 0, 0)
+		writePNGChunk(chunks, "iTXt", append(chunk, pair.value...))
+	}
+	out := make([]byte, 0, len(data)+chunks.Len())
+	out = append(out, data[:headerEnd]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, data[headerEnd:]...), nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// pngMeta reads the metadata from the uncompressed text chunks of a PNG, nil
+// is returned if there aren't any.
+func pngMeta(data []byte) *Metadata {
+	var meta *Metadata
+	for pos := 8; pos+12 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		if size < 0 || pos+12+size > len(data) || typ == "IEND" {
+			break
+		}
+		chunk := data[pos+8 : pos+8+size]
+		pos += 12 + size
+
+		var key, value string
+		switch typ {
+		case "tEXt":
+			i := bytes.IndexByte(chunk, 0)
+			if i < 1 {
+				continue
+			}
+			key, value = fromLatin1(chunk[:i]), fromLatin1(chunk[i+1:])
+		case "iTXt":
+			i := bytes.IndexByte(chunk, 0)
+			// compressed values aren't supported
+			if i < 1 || len(chunk) < i+3 || chunk[i+1] != 0 {
+				continue
+			}
+			rest := chunk[i+3:]
+			// language tag and translated keyword
+			lang := bytes.IndexByte(rest, 0)
+			if lang < 0 {
+				continue
+			}
+			translated := bytes.IndexByte(rest[lang+1:], 0)
+			if translated < 0 {
+				continue
+			}
+			key, value = fromLatin1(chunk[:i]), string(rest[lang+1+translated+1:])
+		default:
+			continue
+		}
+		for k, keyword := range pngKeywords {
+			if key == keyword {
+				key = k
+			}
+		}
+		if meta == nil {
+			meta = &Metadata{}
+		}
+		meta.set(key, value)
+	}
+	return meta
+}
+
+// latin1 encodes the string in Latin-1, ok is false if some characters can't
+// be encoded.
+func latin1(s string) (b []byte, ok bool) {
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, false
+		}
+		b = append(b, byte(r))
+	}
+	return b, true
+}
+
+func fromLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pngKeyWidth is the width of the key column.
+const pngKeyWidth = 64
+
+// pngLayout holds the resolved settings used to draw the patterns.
+type pngLayout struct {
+	opts        PNGOptions
+	theme       Theme
+	stepWidth   int
+	stepHeight  int
+	labelWidth  int
+	keyWidth    int
+	rulerHeight int
+	// stepsPerSystem is the number of steps drawn on each line.
+	stepsPerSystem int
+	systemHeight   int
+	gap            int
+}
+
+// left returns the width of the label and key columns.
+func (l *pngLayout) left() int {
+	return l.labelWidth + l.keyWidth
+}
+
+// stepRect returns the cell of a step of the nth pattern.
+func (l *pngLayout) stepRect(step, n int) image.Rectangle {
+	var system int
+	if l.stepsPerSystem > 0 {
+		system = step / l.stepsPerSystem
+		step %= l.stepsPerSystem
+	}
+	x := l.left() + step*l.stepWidth
+	y := system*(l.systemHeight+l.gap) + l.rulerHeight + n*l.stepHeight
+	return image.Rect(x, y, x+l.stepWidth, y+l.stepHeight)
+}
+
+// drawPatterns draws the patterns, it returns nil if there are no patterns.
+func drawPatterns(patterns []*Pattern, opts PNGOptions) image.Image {
+	img, _ := renderPatterns(patterns, opts)
+	if img == nil {
 		return nil
 	}
+	if opts.Scale > 1 {
+		return scaleImage(img, opts.Scale)
+	}
+	return img
+}
+
+// scaleImage enlarges img by repeating its pixels, without smoothing them.
+func scaleImage(img *image.RGBA, scale int) *image.RGBA {
+	size := img.Bounds().Size()
+	scaled := image.NewRGBA(image.Rect(0, 0, size.X*scale, size.Y*scale))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return scaled
+}
+
+// renderPatterns draws the patterns without scaling them and returns the
+// layout used.
+func renderPatterns(patterns []*Pattern, opts PNGOptions) (*image.RGBA, *pngLayout) {
+	if len(patterns) < 1 {
+		return nil, nil
+	}
 	for _, pat := range patterns {
 		pat.ReAlign()
 	}
 	nbrSteps := len(patterns[0].Pulses)
 
-	stepHeight := 20
-	stepWidth := 20
-	labelWidth := 7 * stepWidth
+	l := &pngLayout{opts: opts, theme: LightTheme}
+	if opts.Theme != nil {
+		l.theme = *opts.Theme
+	}
+	l.stepHeight = opts.StepHeight
+	if l.stepHeight <= 0 {
+		l.stepHeight = 20
+	}
+	l.stepWidth = opts.StepWidth
+	if l.stepWidth <= 0 {
+		l.stepWidth = 20
+	}
+	l.labelWidth = opts.LabelWidth
+	if l.labelWidth <= 0 {
+		l.labelWidth = 7 * l.stepWidth
+	}
+	if opts.HideLabels {
+		l.labelWidth = 0
+	}
+	if opts.ShowKeys {
+		l.keyWidth = pngKeyWidth
+	}
+	if opts.Ruler {
+		l.rulerHeight = l.stepHeight
+	}
 
-	hitFill := color.NRGBA{124, 178, 227, 255}
-	hitStroke := color.NRGBA{30, 30, 30, 255}
-	labelBgColor := color.NRGBA{135, 135, 135, 255}
+	// long patterns are wrapped in systems of BarsPerLine bars
+	stepsPerSystem := nbrSteps
+	barSteps := int(patterns[0].Grid.StepsInBeat()) * 4
+	if opts.BarsPerLine > 0 && opts.BarsPerLine*barSteps < nbrSteps {
+		stepsPerSystem = opts.BarsPerLine * barSteps
+	}
+	nbrSystems := 1
+	if stepsPerSystem > 0 {
+		nbrSystems = (nbrSteps + stepsPerSystem - 1) / stepsPerSystem
+	}
+	systemHeight := l.rulerHeight + len(patterns)*l.stepHeight
+	gap := l.stepHeight / 2
+	l.stepsPerSystem, l.systemHeight, l.gap = stepsPerSystem, systemHeight, gap
 
-	// backgrounds/strokes alternate per row
-	// other is lighter
-	bgColor := color.NRGBA{165, 165, 165, 255}
-	bgColorOther := color.NRGBA{149, 149, 149, 255}
-	gridStrokeColor := color.NRGBA{153, 153, 153, 255}
-	gridStrokeColorOther := color.NRGBA{133, 133, 133, 255}
+	width := l.left() + (stepsPerSystem * l.stepWidth)
+	height := nbrSystems*systemHeight + (nbrSystems-1)*gap
 
-	altBgColor := color.NRGBA{158, 158, 158, 255}
-	altBgColorOther := color.NRGBA{143, 143, 143, 255}
-	altGridStrokeColorOther := color.NRGBA{138, 138, 138, 255}
-	altGridStrokeColor := color.NRGBA{147, 147, 147, 255}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	// label background
+	draw.Draw(img, img.Bounds(), image.NewUniform(l.theme.LabelBackground), image.ZP, draw.Over)
+	for i := 0; i < nbrSystems; i++ {
+		from := i * stepsPerSystem
+		to := from + stepsPerSystem
+		if to > nbrSteps {
+			to = nbrSteps
+		}
+		y := i * (systemHeight + gap)
+		if opts.Ruler {
+			l.drawRuler(img, patterns[0].Grid, y, from, to)
+			y += l.rulerHeight
+		}
+		l.drawSystem(img, patterns, y, from, to, width)
+	}
+	return img, l
+}
+
+// drawRuler draws the bar and beat numbers of the steps from/to at y.
+func (l *pngLayout) drawRuler(img *image.RGBA, grid GridRes, y, from, to int) {
+	stepsInBeat := int(grid.StepsInBeat())
+	if stepsInBeat < 1 {
+		stepsInBeat = 1
+	}
+	beatWidth := stepsInBeat * l.stepWidth
+	for i := from; i <= to; i++ {
+		if i%stepsInBeat != 0 {
+			continue
+		}
+		x := l.left() + (i-from)*l.stepWidth
+		beat := i / stepsInBeat
+		onBar := beat%4 == 0
+		tickTop := y + l.rulerHeight/2
+		col := l.theme.GridStrokes[0][1]
+		if onBar {
+			tickTop = y
+			col = l.theme.HitStroke
+		}
+		for h := tickTop; h < y+l.rulerHeight; h++ {
+			img.Set(x, h, col)
+		}
+		if i == to {
+			break
+		}
+		switch {
+		case onBar:
+			addLabel(img, x+3, y+l.rulerHeight-5, strconv.Itoa(beat/4+1), l.theme.LabelText)
+		case beatWidth >= 16:
+			addLabel(img, x+3, y+l.rulerHeight-5, strconv.Itoa(beat%4+1), l.theme.GridStrokes[0][1])
+		}
+	}
+}
 
-	width := labelWidth + (nbrSteps * stepWidth)
-	height := len(patterns) * stepHeight
+// drawSystem draws the steps from/to of the patterns at y.
+func (l *pngLayout) drawSystem(img *image.RGBA, patterns []*Pattern, top, from, to, width int) {
+	stepWidth, stepHeight := l.stepWidth, l.stepHeight
+	left := l.left()
+	hitStroke := l.theme.HitStroke
+	// backgrounds/strokes alternate per row and per beat
+	bgColor := l.theme.Backgrounds[0][0]
+	gridStrokeColor := l.theme.GridStrokes[0][0]
 
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	// white background
-	draw.Draw(img, image.Rect(0, 0, labelWidth, height), image.NewUniform(labelBgColor), image.ZP, draw.Over)
 	// grid background
-	draw.Draw(img, image.Rect(labelWidth, 0, width, height), image.NewUniform(bgColor), image.ZP, draw.Over)
-
-	var isOtherRow bool
+	draw.Draw(img, image.Rect(left, top, left+(to-from)*stepWidth, top+len(patterns)*stepHeight),
+		image.NewUniform(bgColor), image.ZP, draw.Over)
 
 	// draw the underlying grid
 	for patternIDX, pattern := range patterns {
 		stepsInBeat := int(pattern.Grid.StepsInBeat())
-		patternY := patternIDX * stepHeight
+		patternY := top + patternIDX*stepHeight
 		// line separating each label
-		for x := 0; x < labelWidth; x++ {
+		for x := 0; x < left; x++ {
 			img.Set(x, patternY, gridStrokeColor)
 		}
 
 		var (
-			isAltBeat    bool
 			strokeColor  color.NRGBA
 			bgPaintColor color.NRGBA
 		)
-		isOtherRow = false
-
 		// background alternates per row
-		if patternIDX%2 != 0 {
-			isOtherRow = true
-		}
+		row := patternIDX % 2
 
 		// vertical grid lines
-		for pulseIDX := range pattern.Pulses {
-			x := labelWidth + (pulseIDX * stepWidth)
+		for pulseIDX := from; pulseIDX < to && pulseIDX < len(pattern.Pulses); pulseIDX++ {
+			x := left + ((pulseIDX - from) * stepWidth)
 
 			// detect beats and change the color
 			if pulseIDX%stepsInBeat == 0 {
 				// paint the steps for the entire beat
-				if isAltBeat {
-					if isOtherRow {
-						bgPaintColor = altBgColorOther
-						strokeColor = altGridStrokeColorOther
-					} else {
-						bgPaintColor = altBgColor
-						strokeColor = altGridStrokeColor
-					}
-				} else {
-					if isOtherRow {
-						bgPaintColor = bgColorOther
-						strokeColor = gridStrokeColorOther
-					} else {
-						bgPaintColor = bgColor
-						strokeColor = gridStrokeColor
-					}
-				}
+				beat := (pulseIDX / stepsInBeat) % 2
+				bgPaintColor = l.theme.Backgrounds[beat][row]
+				strokeColor = l.theme.GridStrokes[beat][row]
 
 				// draw the row/beat background
 				draw.Draw(img,
 					// top lef, bottom right
 					image.Rect(x, patternY, x+(stepWidth*stepsInBeat), patternY+stepHeight),
 					image.NewUniform(bgPaintColor), image.ZP, draw.Over)
-				isAltBeat = !isAltBeat
 			}
 			for h := 0; h < stepHeight; h++ {
 				y := patternY + h
@@ -117,20 +478,20 @@ func SaveAsPNG(w io.Writer, patterns []*Pattern) error {
 	}
 	// bottom grid line line
 	for x := 0; x < width; x++ {
-		img.Set(x, stepHeight*(len(patterns)), gridStrokeColor)
+		img.Set(x, top+stepHeight*(len(patterns)), gridStrokeColor)
 	}
 
 	for patternIDX, pattern := range patterns {
-		patternY := patternIDX * stepHeight
+		patternY := top + patternIDX*stepHeight
+
+		hitFill := l.theme.HitFill
+		if len(l.opts.RowColors) > 0 {
+			hitFill = l.opts.RowColors[patternIDX%len(l.opts.RowColors)]
+		}
 
-		isOtherRow = false
 		var bottomY int
 		var heightToPaint int
 		if patternIDX%2 != 0 {
-			isOtherRow = true
-		}
-
-		if isOtherRow {
 			bottomY = patternY + stepHeight - 2
 			heightToPaint = stepHeight - 1
 		} else {
@@ -138,40 +499,70 @@ func SaveAsPNG(w io.Writer, patterns []*Pattern) error {
 			bottomY = patternY + stepHeight - 1
 		}
 
-		for pulseIDX, pulse := range pattern.Pulses {
-			if pulse != nil {
-				for w := 0; w < stepWidth+1; w++ {
-					x := labelWidth + (pulseIDX * stepWidth) + w
-					if w == 0 || w == stepWidth {
-						// vertical stokes at the beginning and end of the pulse
-						for h := 0; h < heightToPaint; h++ {
-							y := patternY + h
-							img.Set(x, y, hitStroke)
-						}
-					} else {
-						for h := 0; h < stepHeight-1; h++ {
-							y := patternY + h
-							img.Set(x, y, hitFill)
-						}
-						// horizontal stokes
-						img.Set(x, patternY, hitStroke)
-						img.Set(x, bottomY, hitStroke)
-					}
+		for pulseIDX := from; pulseIDX < to && pulseIDX < len(pattern.Pulses); pulseIDX++ {
+			pulse := pattern.Pulses[pulseIDX]
+			if pulse == nil {
+				continue
+			}
+			x := left + ((pulseIDX - from) * stepWidth)
+			fill := hitFill
+			if l.opts.VelocityFill != nil {
+				ratio := math.Max(0, math.Min(1, l.opts.VelocityFill(pulse.Velocity)))
+				fill.A = uint8(float64(fill.A) * ratio)
+			}
+			draw.Draw(img, image.Rect(x+1, patternY, x+stepWidth, patternY+stepHeight-1),
+				image.NewUniform(fill), image.ZP, draw.Over)
+			for w := 1; w < stepWidth; w++ {
+				// horizontal stokes
+				img.Set(x+w, patternY, hitStroke)
+				img.Set(x+w, bottomY, hitStroke)
+			}
+			// vertical stokes at the beginning and end of the pulse
+			for h := 0; h < heightToPaint; h++ {
+				img.Set(x, patternY+h, hitStroke)
+				img.Set(x+stepWidth, patternY+h, hitStroke)
+			}
+			drawSubHits(img, pulse, x, patternY, stepWidth, stepHeight, hitStroke)
+		}
+		if !l.opts.HideLabels {
+			addLabel(img, 5, patternY+stepHeight-5, pattern.Name, l.theme.LabelText)
+		}
+		if l.opts.ShowKeys {
+			addLabel(img, l.labelWidth+5, patternY+stepHeight-5,
+				fmt.Sprintf("%-4s %d", midi.NoteToName(pattern.Key), pattern.Key), l.theme.LabelText)
+		}
+	}
+}
 
-				}
+// drawSubHits draws the ratchet divisions and the grace notes inside the cell
+// of a pulse.
+func drawSubHits(img *image.RGBA, pulse *Pulse, x, y, stepWidth, stepHeight int, col color.Color) {
+	if n := int(pulse.Ratchets); n > 1 {
+		for i := 1; i < n; i++ {
+			subX := x + (i * stepWidth / n)
+			for h := stepHeight / 4; h < stepHeight-(stepHeight/4); h++ {
+				img.Set(subX, y+h, col)
 			}
 		}
-		addLabel(img, 5, patternY+15, pattern.Name)
 	}
-	return png.Encode(w, img)
+	graces := 0
+	switch pulse.Ornament {
+	case Flam:
+		graces = 1
+	case Drag:
+		graces = 2
+	}
+	for i := 0; i < graces; i++ {
+		graceX := x + 2 + (i * 4)
+		draw.Draw(img, image.Rect(graceX, y+stepHeight-6, graceX+3, y+stepHeight-3), image.NewUniform(col), image.ZP, draw.Over)
+	}
 }
 
-func addLabel(img *image.RGBA, x, y int, label string) {
+func addLabel(img *image.RGBA, x, y int, label string, col color.Color) {
 	// truncate the labels to fit
 	if len(label) > 16 {
 		label = label[:16]
 	}
-	col := color.RGBA{0, 0, 0, 255}
 	point := fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
 
 	d := &font.Drawer{
@@ -182,3 +573,166 @@ func addLabel(img *image.RGBA, x, y int, label string) {
 	}
 	d.DrawString(label)
 }
+
+// ErrUnknownImageLayout is returned when decoding an image that wasn't drawn
+// using the SaveAsPNG layout.
+var ErrUnknownImageLayout = errors.New("the image doesn't use the SaveAsPNG layout")
+
+// imgGrids are the grids tried when guessing the resolution of an
+// image, the most common first.
+var imgGrids = []GridRes{One16, One8, One32, One4, One64}
+
+// FromPNG reads an image drawn by SaveAsPNG and converts its grid back into
+// patterns. See FromImage. The metadata saved with the patterns is restored.
+func FromPNG(r io.Reader) ([]*Pattern, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := FromImage(img)
+	if err != nil {
+		return nil, err
+	}
+	setMeta(patterns, pngMeta(data))
+	return patterns, nil
+}
+
+// FromImage converts an image using the default layout of SaveAsPNG back into
+// patterns, the image can be scaled up by an integer factor such as a high-DPI
+// screenshot. The grid resolution is guessed from the beat shading and the
+// hits get the default velocity. The names and keys aren't part of the image
+// and are left empty.
+func FromImage(img image.Image) ([]*Pattern, error) {
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 {
+		return nil, ErrUnknownImageLayout
+	}
+	at := func(x, y int) color.NRGBA {
+		return color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+	}
+
+	// the bottom line of the labels is only made of the label background
+	var theme *Theme
+	bottom := b.Dy() - 1
+	for _, t := range []*Theme{&LightTheme, &DarkTheme} {
+		if colorDistance(at(0, bottom), t.LabelBackground) <= 12 {
+			theme = t
+			break
+		}
+	}
+	if theme == nil {
+		return nil, ErrUnknownImageLayout
+	}
+	labelEnd := 0
+	for labelEnd < b.Dx() && colorDistance(at(labelEnd, bottom), theme.LabelBackground) <= 12 {
+		labelEnd++
+	}
+	// the first grid line can be mistaken for the label background
+	scale := (labelEnd + 70) / 140
+	if scale < 1 || labelEnd-140*scale > scale {
+		return nil, ErrUnknownImageLayout
+	}
+	stepSize := 20 * scale
+	labelWidth := 140 * scale
+	if (b.Dx()-labelWidth)%stepSize != 0 || b.Dy()%stepSize != 0 || b.Dx() == labelWidth {
+		return nil, ErrUnknownImageLayout
+	}
+	nbrSteps := (b.Dx() - labelWidth) / stepSize
+	nbrRows := b.Dy() / stepSize
+	// cell returns the color at the x, y coordinates of a step in the
+	// unscaled image.
+	cell := func(row, step, x, y int) color.NRGBA {
+		return at(labelWidth+(step*20+x)*scale+scale/2, (row*20+y)*scale+scale/2)
+	}
+
+	// beat shading of the steps, -1 when all the rows are hit
+	parity := make([]int, nbrSteps)
+	hits := make([][]bool, nbrRows)
+	for row := range hits {
+		hits[row] = make([]bool, nbrSteps)
+		bgs := []color.NRGBA{theme.Backgrounds[0][row%2], theme.Backgrounds[1][row%2]}
+		for step := range hits[row] {
+			if row == 0 {
+				parity[step] = -1
+			}
+			c := cell(row, step, 10, 10)
+			d0, d1 := colorDistance(c, bgs[0]), colorDistance(c, bgs[1])
+			switch {
+			case d0 > 12 && d1 > 12:
+				hits[row][step] = true
+			case d0 < d1:
+				parity[step] = 0
+			default:
+				parity[step] = 1
+			}
+		}
+	}
+	var grid GridRes
+	for _, g := range imgGrids {
+		stepsInBeat := int(g.StepsInBeat())
+		match := true
+		for step, p := range parity {
+			if p != -1 && p != (step/stepsInBeat)%2 {
+				match = false
+				break
+			}
+		}
+		if match {
+			grid = g
+			break
+		}
+	}
+	if grid == "" {
+		return nil, ErrUnknownImageLayout
+	}
+
+	isStroke := func(c color.NRGBA) bool {
+		return colorDistance(c, theme.HitStroke) <= 12
+	}
+	patterns := make([]*Pattern, nbrRows)
+	for row := range patterns {
+		pat := &Pattern{PPQN: DefaultPPQN, Grid: grid, Pulses: make(Pulses, nbrSteps)}
+		ticks := pat.StepSize()
+		for step, hit := range hits[row] {
+			if !hit {
+				continue
+			}
+			pulse := &Pulse{Ticks: uint64(step) * ticks, Duration: uint16(ticks), Velocity: 90}
+			// ratchet divisions
+			var ratchets uint8
+			for x := 1; x < 20; x++ {
+				if isStroke(cell(row, step, x, 10)) {
+					ratchets++
+				}
+			}
+			if ratchets > 0 {
+				pulse.Ratchets = ratchets + 1
+			}
+			// grace notes
+			if isStroke(cell(row, step, 3, 15)) {
+				pulse.Ornament = Flam
+				if isStroke(cell(row, step, 7, 15)) {
+					pulse.Ornament = Drag
+				}
+			}
+			pat.Pulses[step] = pulse
+		}
+		patterns[row] = pat
+	}
+	return patterns, nil
+}
+
+// colorDistance returns the sum of the differences of the RGB channels.
+func colorDistance(a, b color.NRGBA) int {
+	abs := func(v int) int {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	return abs(int(a.R)-int(b.R)) + abs(int(a.G)-int(b.G)) + abs(int(a.B)-int(b.B))
+}
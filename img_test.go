@@ -0,0 +1,218 @@
+package drumbeat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestSaveAsPNGWithOptions(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	newPatterns := func() []*Pattern {
+		patterns := NewFromString(One16, `
+			[kick]	x...;
+			[snare]	x...`)
+		patterns[0].Pulses[0].Velocity = 127
+		patterns[1].Pulses[0].Velocity = 0
+		return patterns
+	}
+	tests := []struct {
+		name       string
+		bars       int
+		opts       PNGOptions
+		wantSize   image.Point
+		wantPixels map[image.Point]color.NRGBA
+	}{
+		{name: "default",
+			wantSize: image.Pt(460, 40),
+			wantPixels: map[image.Point]color.NRGBA{
+				{150, 10}: LightTheme.HitFill,
+				{170, 10}: LightTheme.Backgrounds[0][0],
+				{1, 1}:    LightTheme.LabelBackground,
+			},
+		},
+		{name: "dark theme",
+			opts:     PNGOptions{Theme: &DarkTheme},
+			wantSize: image.Pt(460, 40),
+			wantPixels: map[image.Point]color.NRGBA{
+				{150, 10}: DarkTheme.HitFill,
+				{170, 30}: DarkTheme.Backgrounds[0][1],
+				{1, 1}:    DarkTheme.LabelBackground,
+			},
+		},
+		{name: "layout",
+			opts:     PNGOptions{StepWidth: 10, StepHeight: 10, LabelWidth: 60, Scale: 2},
+			wantSize: image.Pt(2*(60+16*10), 2*20),
+			wantPixels: map[image.Point]color.NRGBA{
+				{2 * 65, 2 * 5}: LightTheme.HitFill,
+			},
+		},
+		{name: "no labels",
+			opts:     PNGOptions{HideLabels: true},
+			wantSize: image.Pt(320, 40),
+			wantPixels: map[image.Point]color.NRGBA{
+				{10, 10}: LightTheme.HitFill,
+			},
+		},
+		{name: "row colors and velocity",
+			opts:     PNGOptions{RowColors: []color.NRGBA{red}, VelocityFill: func(v uint8) float64 { return float64(v) / 127 }},
+			wantSize: image.Pt(460, 40),
+			wantPixels: map[image.Point]color.NRGBA{
+				{150, 10}: red,
+				// no velocity, the background shows through
+				{150, 30}: LightTheme.Backgrounds[0][1],
+			},
+		},
+		{name: "ruler and keys",
+			opts:     PNGOptions{Ruler: true, ShowKeys: true},
+			wantSize: image.Pt(140+64+320, 60),
+			wantPixels: map[image.Point]color.NRGBA{
+				// bar line
+				{204, 0}: LightTheme.HitStroke,
+				// beat tick
+				{284, 5}:  LightTheme.LabelBackground,
+				{284, 15}: LightTheme.GridStrokes[0][1],
+				{214, 30}: LightTheme.HitFill,
+			},
+		},
+		{name: "wrapped",
+			bars:     4,
+			opts:     PNGOptions{Ruler: true, BarsPerLine: 2},
+			wantSize: image.Pt(140+640, 2*60+10),
+			wantPixels: map[image.Point]color.NRGBA{
+				{140, 0}:  LightTheme.HitStroke,
+				{150, 30}: LightTheme.HitFill,
+				// gap between the systems
+				{150, 65}: LightTheme.LabelBackground,
+				{140, 70}: LightTheme.HitStroke,
+				// last step of the 4th bar
+				{770, 100}: LightTheme.HitFill,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			patterns := newPatterns()
+			if tt.bars > 1 {
+				extend := make(Pulses, 16*tt.bars)
+				copy(extend, patterns[0].Pulses)
+				extend[len(extend)-1] = &Pulse{Ticks: uint64(len(extend)-1) * 24, Velocity: 90}
+				patterns[0].Pulses = extend
+			}
+			if err := SaveAsPNGWithOptions(buf, patterns, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size != tt.wantSize {
+				t.Errorf("expected a %v image, got %v", tt.wantSize, size)
+			}
+			for pt, want := range tt.wantPixels {
+				if got := color.NRGBAModel.Convert(img.At(pt.X, pt.Y)); got != want {
+					t.Errorf("expected the pixel at %v to be %v, got %v", pt, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDrawPatterns_scale(t *testing.T) {
+	patterns := NewFromString(One16, "[kick]\tx...x...;[snare]\t....x...")
+	img := drawPatterns(patterns, PNGOptions{})
+	zoomed := drawPatterns(patterns, PNGOptions{Scale: 3})
+	b := img.Bounds()
+	if zoomed.Bounds().Size() != b.Size().Mul(3) {
+		t.Fatalf("expected a %v image, got %v", b.Size().Mul(3), zoomed.Bounds().Size())
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := img.At(x, y)
+			for _, pt := range []image.Point{{3 * x, 3 * y}, {3*x + 2, 3*y + 2}} {
+				if got := zoomed.At(pt.X, pt.Y); got != want {
+					t.Fatalf("expected the pixel at %v to repeat %v, got %v", pt, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestFromPNG(t *testing.T) {
+	tests := []struct {
+		name     string
+		grid     GridRes
+		patterns string
+		opts     PNGOptions
+	}{
+		{name: "16th",
+			grid: One16,
+			patterns: `
+				[kick]	x...x...x...x.x.;
+				[snare]	....x.......x...;
+				[hihat]	x.x.x.x.x.x.x.xx`,
+		},
+		{name: "8th in 2 bars",
+			grid: One8,
+			patterns: `
+				[kick]	x...x...x.x.....;
+				[snare]	..x...x...x...xx`,
+		},
+		{name: "ratchets and ornaments",
+			grid: One16,
+			patterns: `
+				[snare]	x(r3)...x(flam)...x(drag)...x(r2,flam)...`,
+		},
+		{name: "dark theme scaled",
+			grid:     One16,
+			opts:     PNGOptions{Theme: &DarkTheme, Scale: 3},
+			patterns: `[kick]	x...x...;[hat]	xxxx`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := NewFromString(tt.grid, tt.patterns)
+			buf := &bytes.Buffer{}
+			if err := SaveAsPNGWithOptions(buf, patterns, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			got, err := FromPNG(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(patterns) {
+				t.Fatalf("expected %d patterns, got %d", len(patterns), len(got))
+			}
+			for i, pat := range got {
+				if pat.Grid != tt.grid {
+					t.Errorf("[%d] expected the %s grid, got %s", i, tt.grid, pat.Grid)
+				}
+				if len(pat.Pulses) != len(patterns[i].Pulses) {
+					t.Fatalf("[%d] expected %d steps, got %d", i, len(patterns[i].Pulses), len(pat.Pulses))
+				}
+				for j, want := range patterns[i].Pulses {
+					p := pat.Pulses[j]
+					if (want == nil) != (p == nil) {
+						t.Fatalf("[%d] expected the hit of step %d to be %v, got %v", i, j, want, p)
+					}
+					if want == nil {
+						continue
+					}
+					if p.Ticks != want.Ticks || p.Ornament != want.Ornament || p.Ratchets != want.Ratchets {
+						t.Errorf("[%d] expected step %d to be %+v, got %+v", i, j, want, p)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("unknown layout", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 40))
+		if _, err := FromImage(img); err != ErrUnknownImageLayout {
+			t.Fatalf("expected ErrUnknownImageLayout, got %v", err)
+		}
+	})
+}
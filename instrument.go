@@ -0,0 +1,138 @@
+package drumbeat
+
+import "strings"
+
+// Instrument is the role of a pattern in a drum kit.
+type Instrument string
+
+const (
+	Unknown     Instrument = ""
+	Kick        Instrument = "kick"
+	Snare       Instrument = "snare"
+	Rimshot     Instrument = "rimshot"
+	Clap        Instrument = "clap"
+	ClosedHiHat Instrument = "closed hihat"
+	PedalHiHat  Instrument = "pedal hihat"
+	OpenHiHat   Instrument = "open hihat"
+	LowTom      Instrument = "low tom"
+	MidTom      Instrument = "mid tom"
+	HighTom     Instrument = "high tom"
+	Crash       Instrument = "crash"
+	Ride        Instrument = "ride"
+	Cowbell     Instrument = "cowbell"
+	Shaker      Instrument = "shaker"
+	Percussion  Instrument = "percussion"
+)
+
+// GMDrumMap maps the General MIDI percussion keys to instruments.
+var GMDrumMap = map[int]Instrument{
+	35: Kick,
+	36: Kick,
+	37: Rimshot,
+	38: Snare,
+	39: Clap,
+	40: Snare,
+	41: LowTom,
+	42: ClosedHiHat,
+	43: LowTom,
+	44: PedalHiHat,
+	45: MidTom,
+	46: OpenHiHat,
+	47: MidTom,
+	48: HighTom,
+	49: Crash,
+	50: HighTom,
+	51: Ride,
+	52: Crash,
+	53: Ride,
+	54: Percussion,
+	55: Crash,
+	56: Cowbell,
+	57: Crash,
+	59: Ride,
+	69: Shaker,
+	70: Shaker,
+}
+
+// GMKeys are the default General MIDI keys used for each instrument.
+var GMKeys = map[Instrument]int{
+	Kick:        36,
+	Rimshot:     37,
+	Snare:       38,
+	Clap:        39,
+	ClosedHiHat: 42,
+	PedalHiHat:  44,
+	OpenHiHat:   46,
+	LowTom:      45,
+	MidTom:      47,
+	HighTom:     50,
+	Crash:       49,
+	Ride:        51,
+	Cowbell:     56,
+	Shaker:      70,
+	Percussion:  54,
+}
+
+// instrument names are checked in order so the more specific names are matched
+// first.
+var instrumentNames = []struct {
+	names []string
+	inst  Instrument
+}{
+	{[]string{"open hat", "open hihat", "open hi-hat", "open hi hat", "open hh", "hihat open", "hi-hat open", "hi hat open", "hh open", "hat open", "ohh", "oh"}, OpenHiHat},
+	{[]string{"pedal"}, PedalHiHat},
+	{[]string{"hihat", "hi-hat", "hi hat", "closed hat", "closed hh", "hh closed", "hat", "hh", "chh", "ch"}, ClosedHiHat},
+	{[]string{"low tom", "floor tom", "lo tom", "tom low", "tom lo", "tom 3", "tom3", "lt"}, LowTom},
+	{[]string{"mid tom", "tom mid", "tom 2", "tom2", "mt"}, MidTom},
+	{[]string{"high tom", "hi tom", "tom high", "tom hi", "tom 1", "tom1", "ht", "tom"}, HighTom},
+	{[]string{"kick", "bass drum", "bd", "kck"}, Kick},
+	{[]string{"rimshot", "side stick", "rim"}, Rimshot},
+	{[]string{"snare", "sd", "snr"}, Snare},
+	{[]string{"clap", "cp"}, Clap},
+	{[]string{"ride", "rd"}, Ride},
+	{[]string{"crash", "china", "splash", "cymbal", "cy"}, Crash},
+	{[]string{"cowbell", "cb"}, Cowbell},
+	{[]string{"shaker", "maraca", "sh"}, Shaker},
+	{[]string{"perc", "conga", "bongo", "clave"}, Percussion},
+}
+
+// Instrument returns the role of the pattern based on its name and falls back
+// to the General MIDI drum map using its key.
+func (p *Pattern) Instrument() Instrument {
+	if p == nil {
+		return Unknown
+	}
+	if inst := InstrumentFromName(p.Name); inst != Unknown {
+		return inst
+	}
+	return GMDrumMap[p.Key]
+}
+
+// InstrumentFromName guesses the instrument based on its name, the
+// underscores and repeated spaces are read as single spaces.
+func InstrumentFromName(name string) Instrument {
+	name = strings.ToLower(strings.Join(strings.Fields(strings.Replace(name, "_", " ", -1)), " "))
+	if name == "" {
+		return Unknown
+	}
+	for _, in := range instrumentNames {
+		for _, n := range in.names {
+			// short names need to be an exact match
+			if len(n) < 4 {
+				if name == n {
+					return in.inst
+				}
+				continue
+			}
+			if strings.Contains(name, n) {
+				return in.inst
+			}
+		}
+	}
+	return Unknown
+}
+
+// IsTom returns true if the instrument is one of the toms.
+func (i Instrument) IsTom() bool {
+	return i == LowTom || i == MidTom || i == HighTom
+}
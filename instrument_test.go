@@ -0,0 +1,42 @@
+package drumbeat
+
+import "testing"
+
+func TestPattern_Instrument(t *testing.T) {
+	tests := []struct {
+		name string
+		key  int
+		want Instrument
+	}{
+		{name: "kick", want: Kick},
+		{name: "Kick 808", want: Kick},
+		{name: "hihat", want: ClosedHiHat},
+		{name: "hihat open", want: OpenHiHat},
+		{name: "Open Hat", want: OpenHiHat},
+		{name: "Hi Hat", want: ClosedHiHat},
+		{name: "Closed HH", want: ClosedHiHat},
+		{name: "Open HH", want: OpenHiHat},
+		{name: "Hi  Hat Open", want: OpenHiHat},
+		{name: "open_hh", want: OpenHiHat},
+		{name: "Tom Hi", want: HighTom},
+		{name: "Tom Lo", want: LowTom},
+		{name: "Floor Tom", want: LowTom},
+		{name: "tom", want: HighTom},
+		{name: "Rimshot", want: Rimshot},
+		{name: "Ride Cymbal", want: Ride},
+		{name: "Crash Cymbal", want: Crash},
+		{name: "China", want: Crash},
+		{name: "snare", key: 36, want: Snare},
+		{name: "C1", key: 36, want: Kick},
+		{name: "", key: 46, want: OpenHiHat},
+		{name: "synth", key: 60, want: Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pattern{Name: tt.name, Key: tt.key}
+			if got := p.Instrument(); got != tt.want {
+				t.Errorf("Pattern.Instrument() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
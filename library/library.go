@@ -0,0 +1,309 @@
+// Package library indexes a directory of pattern files so they can be
+// searched by their metadata.
+package library
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/drumbeat/analysis"
+	"github.com/mattetti/drumbeat/generators"
+)
+
+// IndexFile is the name of the file, in the root of the library, where the
+// index is saved.
+const IndexFile = ".drumbeat-index"
+
+// Formats of the pattern files.
+const (
+	Text = "text"
+	MIDI = "midi"
+	Gob  = "gob"
+	JSON = "json"
+)
+
+// Extensions maps the extensions of the files indexed to their format.
+var Extensions = map[string]string{
+	".txt":  Text,
+	".drum": Text,
+	".mid":  MIDI,
+	".midi": MIDI,
+	".gob":  Gob,
+	".json": JSON,
+}
+
+// TextGrid is the grid of the patterns read from text files.
+var TextGrid = drumbeat.One16
+
+// Entry is the indexed metadata of a pattern file.
+type Entry struct {
+	// Path of the file, relative to the root of the library and using
+	// forward slashes.
+	Path    string
+	ModTime time.Time
+	Size    int64
+	Format  string
+	// Name is the name of the file without its extension.
+	Name  string
+	Title string
+	// Tags are the directories containing the file, lower cased, followed by
+	// the tags of the metadata.
+	Tags []string
+	// BPM is read from the metadata or from the file name, such as
+	// "funk 96bpm.mid", 0 if unknown.
+	BPM float64
+	// Style is read from the metadata or is the generators style found in the
+	// path, if any.
+	Style       string
+	Bars        int
+	Instruments []drumbeat.Instrument
+	// Density is the ratio of steps played by any of the patterns.
+	Density float64
+	// Fingerprint is the analysis fingerprint of the patterns, shared by the
+	// duplicated grooves.
+	Fingerprint string
+	// Err is set when the file couldn't be read, such entries aren't matched
+	// by the queries until the file changes.
+	Err string `json:",omitempty"`
+}
+
+// Has returns true if one of the patterns plays the instrument.
+func (e *Entry) Has(inst drumbeat.Instrument) bool {
+	for _, i := range e.Instruments {
+		if i == inst {
+			return true
+		}
+	}
+	return false
+}
+
+// Library is a directory of pattern files and the index of their metadata.
+// It isn't safe for concurrent use.
+type Library struct {
+	Root    string
+	entries map[string]*Entry
+}
+
+// Open loads the index of the library rooted at the directory and updates
+// it, the index is created if needed.
+func Open(root string) (*Library, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s isn't a directory", root)
+	}
+	l := &Library{Root: root, entries: map[string]*Entry{}}
+	data, err := ioutil.ReadFile(filepath.Join(root, IndexFile))
+	switch {
+	case err == nil:
+		var entries []*Entry
+		// a corrupted index is rebuilt
+		if json.Unmarshal(data, &entries) == nil {
+			for _, e := range entries {
+				l.entries[e.Path] = e
+			}
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	if _, err := l.Update(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update indexes the files added or modified since the last update and
+// removes the deleted ones. It returns the number of entries which changed
+// and saves the index if needed.
+func (l *Library) Update() (int, error) {
+	var changed int
+	seen := map[string]bool{}
+	err := filepath.Walk(l.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != l.Root && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		format, ok := Extensions[strings.ToLower(filepath.Ext(path))]
+		if info.IsDir() || !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		if e, ok := l.entries[rel]; ok && e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		l.entries[rel] = index(path, rel, format, info)
+		changed++
+		return nil
+	})
+	if err != nil {
+		return changed, err
+	}
+	for path := range l.entries {
+		if !seen[path] {
+			delete(l.entries, path)
+			changed++
+		}
+	}
+	if changed > 0 {
+		return changed, l.Save()
+	}
+	return changed, nil
+}
+
+// Save writes the index to the root of the library.
+func (l *Library) Save() error {
+	data, err := json.MarshalIndent(l.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(l.Root, IndexFile), data, 0644)
+}
+
+// Entries returns the indexed files sorted by path, including the ones which
+// couldn't be read.
+func (l *Library) Entries() []*Entry {
+	entries := make([]*Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Find returns the entries matching the query, see ParseQuery.
+func (l *Library) Find(query string) ([]*Entry, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, e := range l.Entries() {
+		if e.Err == "" && q.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Load reads the patterns of an entry.
+func (l *Library) Load(e *Entry) ([]*drumbeat.Pattern, error) {
+	return load(filepath.Join(l.Root, filepath.FromSlash(e.Path)), e.Format)
+}
+
+func load(path, format string) ([]*drumbeat.Pattern, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []*drumbeat.Pattern
+	r := bytes.NewReader(data)
+	switch format {
+	case Text:
+		patterns = drumbeat.NewFromString(TextGrid, strings.TrimSpace(string(data)))
+	case MIDI:
+		patterns, err = drumbeat.FromMIDI(r)
+	case Gob:
+		patterns, err = drumbeat.ReadFrom(r)
+	case JSON:
+		patterns, err = drumbeat.ReadJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	return patterns, nil
+}
+
+var bpmRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*bpm`)
+
+// index reads the file and extracts its metadata.
+func index(path, rel, format string, info os.FileInfo) *Entry {
+	e := &Entry{
+		Path:    rel,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Format:  format,
+		Name:    strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel)),
+	}
+	if dir := filepath.ToSlash(filepath.Dir(rel)); dir != "." {
+		for _, tag := range strings.Split(dir, "/") {
+			e.Tags = append(e.Tags, strings.ToLower(tag))
+		}
+	}
+	if m := bpmRe.FindStringSubmatch(e.Name); m != nil {
+		e.BPM, _ = strconv.ParseFloat(m[1], 64)
+	}
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(rel), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ") + " "
+	for _, style := range generators.Styles {
+		if strings.Contains(words, " "+string(style)+" ") {
+			e.Style = string(style)
+			break
+		}
+	}
+
+	patterns, err := load(path, format)
+	if err == nil && len(patterns) == 0 {
+		err = fmt.Errorf("no patterns")
+	}
+	if err != nil {
+		e.Err = err.Error()
+		return e
+	}
+	if meta := patterns[0].Meta; meta != nil {
+		e.Title = meta.Title
+		if meta.BPM > 0 {
+			e.BPM = meta.BPM
+		}
+		if meta.Style != "" {
+			e.Style = normalize(meta.Style)
+		}
+		for _, tag := range meta.Tags {
+			e.Tags = append(e.Tags, strings.ToLower(tag))
+		}
+	}
+	// the patterns are fingerprinted over full bars
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	report := analysis.Analyze(patterns...)
+	if stepsInBar := int(report.Grid.StepsInBeat()) * 4; stepsInBar > 0 {
+		e.Bars = (report.Steps + stepsInBar - 1) / stepsInBar
+	}
+	e.Density = report.Combined.Density
+	for inst := range report.Density {
+		if inst != "" {
+			e.Instruments = append(e.Instruments, inst)
+		}
+	}
+	sort.Slice(e.Instruments, func(i, j int) bool { return e.Instruments[i] < e.Instruments[j] })
+	e.Fingerprint = analysis.Fingerprint(patterns...)
+	return e
+}
@@ -0,0 +1,198 @@
+package library
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newLibrary(t *testing.T) (*Library, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "drumbeat-library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, root, "house/classic 124bpm.txt", "[kick]\tx...x...x...x...;\n[clap]\t....x.......x...;\n[hihat]\t..x...x...x...x.")
+	writeFile(t, root, "house/deep/shuffle.txt", "[kick]\tx...x...x...x...\tx...x...x...x...;\n[hihat]\t..x...x...x...x.")
+	writeFile(t, root, "drum-and-bass/amen 170BPM.drum", "[kick]\tx.x.......xx....;\n[snare]\t....x..x.x..x..x")
+	writeFile(t, root, "broken.json", "{")
+	writeFile(t, root, "notes.md", "not a pattern")
+
+	f, err := os.Create(filepath.Join(root, "rock.mid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := drumbeat.ToMIDI(f, drumbeat.NewFromString(drumbeat.One16, "[kick]\t{C1}\tx.......x.......;\n[snare]\t{D1}\t....x.......x...")...); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l, err := Open(root)
+	if err != nil {
+		os.RemoveAll(root)
+		t.Fatal(err)
+	}
+	return l, func() { os.RemoveAll(root) }
+}
+
+func TestOpen(t *testing.T) {
+	l, cleanup := newLibrary(t)
+	defer cleanup()
+
+	var paths []string
+	for _, e := range l.Entries() {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"broken.json", "drum-and-bass/amen 170BPM.drum", "house/classic 124bpm.txt", "house/deep/shuffle.txt", "rock.mid"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected the entries %q, got %q", want, paths)
+	}
+
+	e := l.entries["house/classic 124bpm.txt"]
+	if e.Name != "classic 124bpm" || e.Format != Text || e.BPM != 124 || e.Style != "house" || e.Bars != 1 {
+		t.Errorf("unexpected metadata %+v", e)
+	}
+	if want := []drumbeat.Instrument{drumbeat.Clap, drumbeat.ClosedHiHat, drumbeat.Kick}; !reflect.DeepEqual(e.Instruments, want) {
+		t.Errorf("expected the instruments %v, got %v", want, e.Instruments)
+	}
+	if e.Density != 0.5 {
+		t.Errorf("expected a density of %v, got %v", 0.5, e.Density)
+	}
+	if e := l.entries["house/deep/shuffle.txt"]; !reflect.DeepEqual(e.Tags, []string{"house", "deep"}) || e.Bars != 2 {
+		t.Errorf("unexpected tags %v and bars %d", e.Tags, e.Bars)
+	}
+	if e := l.entries["drum-and-bass/amen 170BPM.drum"]; e.Style != "drum and bass" || e.BPM != 170 {
+		t.Errorf("unexpected style %q and BPM %v", e.Style, e.BPM)
+	}
+	if e := l.entries["rock.mid"]; e.Format != MIDI || !e.Has(drumbeat.Snare) {
+		t.Errorf("unexpected MIDI entry %+v", e)
+	}
+	if e := l.entries["broken.json"]; e.Err == "" {
+		t.Error("expected the broken file to have an error")
+	}
+	if _, err := os.Stat(filepath.Join(l.Root, IndexFile)); err != nil {
+		t.Errorf("expected the index to be saved - %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	l, cleanup := newLibrary(t)
+	defer cleanup()
+
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{query: "", want: []string{"drum-and-bass/amen 170BPM.drum", "house/classic 124bpm.txt", "house/deep/shuffle.txt", "rock.mid"}},
+		{query: "style=house has=clap", want: []string{"house/classic 124bpm.txt"}},
+		{query: "style=house bars=2", want: []string{"house/deep/shuffle.txt"}},
+		{query: "style=drum-and-bass", want: []string{"drum-and-bass/amen 170BPM.drum"}},
+		{query: "bpm>=120", want: []string{"drum-and-bass/amen 170BPM.drum", "house/classic 124bpm.txt"}},
+		{query: "has!=hihat format=text", want: []string{"drum-and-bass/amen 170BPM.drum"}},
+		{query: "tag=deep", want: []string{"house/deep/shuffle.txt"}},
+		{query: "AMEN", want: []string{"drum-and-bass/amen 170BPM.drum"}},
+		{query: "density<0.3", want: []string{"rock.mid"}},
+		{query: "bars>two", wantErr: true},
+		{query: "style>house", wantErr: true},
+		{query: "color=red", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			entries, err := l.Find(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			paths := []string{}
+			for _, e := range entries {
+				paths = append(paths, e.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, paths)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	l, cleanup := newLibrary(t)
+	defer cleanup()
+
+	if n, err := l.Update(); err != nil || n != 0 {
+		t.Fatalf("expected no changes, got %d - %v", n, err)
+	}
+
+	writeFile(t, l.Root, "house/classic 124bpm.txt", "[kick]\tx...x...x...x...;\n[snare]\t....x.......x...")
+	// make sure the modification time changes on coarse file systems
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(l.Root, "house", "classic 124bpm.txt"), later, later)
+	writeFile(t, l.Root, "techno/rumble.txt", "[kick]\tx...x...x...x...")
+	if err := os.Remove(filepath.Join(l.Root, "rock.mid")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := l.Update(); err != nil || n != 3 {
+		t.Fatalf("expected 3 changes, got %d - %v", n, err)
+	}
+	if e := l.entries["house/classic 124bpm.txt"]; e.Has(drumbeat.Clap) || !e.Has(drumbeat.Snare) {
+		t.Errorf("expected the modified file to be indexed again, got %v", e.Instruments)
+	}
+	if _, ok := l.entries["rock.mid"]; ok {
+		t.Error("expected the deleted file to be removed")
+	}
+
+	// the saved index is reused
+	reopened, err := Open(l.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.entries) != len(l.entries) {
+		t.Errorf("expected %d entries, got %d", len(l.entries), len(reopened.entries))
+	}
+	if e := reopened.entries["techno/rumble.txt"]; e == nil || e.Style != "techno" || e.Fingerprint != l.entries["techno/rumble.txt"].Fingerprint {
+		t.Errorf("unexpected reloaded entry %+v", e)
+	}
+}
+
+func TestIndexMetadata(t *testing.T) {
+	root, err := ioutil.TempDir("", "drumbeat-library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFile(t, root, "house/loop 120bpm.txt", "@title=Sunday Groove\n@bpm=98\n@style=Techno\n@tags=Live\n[kick]\tx...x...x...x...")
+	writeFile(t, root, "breaks.txt", "@style=Drum_and-Bass\n[kick]\tx.........x.....")
+
+	l, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := l.entries["house/loop 120bpm.txt"]
+	if e.Title != "Sunday Groove" || e.BPM != 98 || e.Style != "techno" || !reflect.DeepEqual(e.Tags, []string{"house", "live"}) {
+		t.Errorf("expected the metadata to override the path, got %+v", e)
+	}
+	if entries, err := l.Find("sunday tag=live"); err != nil || len(entries) != 1 {
+		t.Errorf("expected to find the entry by title and tag, got %v - %v", entries, err)
+	}
+	if entries, err := l.Find("style=drum-and_bass"); err != nil || len(entries) != 1 || entries[0].Style != "drum and bass" {
+		t.Errorf("expected to find the entry by its normalized style, got %v - %v", entries, err)
+	}
+}
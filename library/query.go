@@ -0,0 +1,144 @@
+package library
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// Query is a parsed search, an entry matches when all of its terms match.
+type Query []Term
+
+// Term is a single condition of a query, such as bars>=2.
+type Term struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// operators are checked in order so the 2 character ones are found first.
+var operators = []string{">=", "<=", "!=", "=", ">", "<"}
+
+// numeric keys can be compared using all the operators, the other keys only
+// support = and !=.
+var numeric = map[string]bool{"bpm": true, "bars": true, "density": true}
+
+var textKeys = map[string]bool{"name": true, "tag": true, "style": true, "has": true, "format": true, "fingerprint": true}
+
+// ParseQuery parses space separated terms such as "style=house bars=2
+// has=clap bpm>=120". The keys are:
+//
+//	name         part of the file name or title
+//	tag          one of the directories containing the file or metadata tags
+//	style        style of the metadata or found in the path, dashes match spaces
+//	has          instrument played, such as clap or open-hihat
+//	format       text, midi, gob or json
+//	fingerprint  analysis fingerprint, to find the duplicates
+//	bpm, bars, density
+//
+// A term without operator matches the name or one of the tags.
+func ParseQuery(s string) (Query, error) {
+	q := Query{}
+	for _, field := range strings.Fields(s) {
+		t := Term{Key: "name", Op: "~", Value: field}
+		for _, op := range operators {
+			if i := strings.Index(field, op); i >= 0 {
+				t = Term{Key: strings.ToLower(field[:i]), Op: op, Value: field[i+len(op):]}
+				break
+			}
+		}
+		switch {
+		case numeric[t.Key]:
+			if _, err := strconv.ParseFloat(t.Value, 64); err != nil {
+				return nil, fmt.Errorf("invalid number in the query term %q", field)
+			}
+		case textKeys[t.Key]:
+			if t.Op != "=" && t.Op != "!=" && t.Op != "~" {
+				return nil, fmt.Errorf("operator %s isn't supported by %s", t.Op, t.Key)
+			}
+		default:
+			return nil, fmt.Errorf("unknown key in the query term %q", field)
+		}
+		t.Value = normalize(t.Value)
+		q = append(q, t)
+	}
+	return q, nil
+}
+
+// Match returns true if the entry matches all the terms.
+func (q Query) Match(e *Entry) bool {
+	for _, t := range q {
+		if !t.Match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns true if the entry matches the term.
+func (t Term) Match(e *Entry) bool {
+	if numeric[t.Key] {
+		var v float64
+		switch t.Key {
+		case "bpm":
+			v = e.BPM
+		case "bars":
+			v = float64(e.Bars)
+		case "density":
+			v = e.Density
+		}
+		want, _ := strconv.ParseFloat(t.Value, 64)
+		switch t.Op {
+		case "=":
+			return v == want
+		case "!=":
+			return v != want
+		case ">":
+			return v > want
+		case ">=":
+			return v >= want
+		case "<":
+			return v < want
+		case "<=":
+			return v <= want
+		}
+		return false
+	}
+
+	var match bool
+	name := normalize(e.Name + "\n" + e.Title)
+	switch t.Key {
+	case "name":
+		match = strings.Contains(name, t.Value)
+		if t.Op == "~" {
+			for _, tag := range e.Tags {
+				match = match || strings.Contains(tag, t.Value)
+			}
+		}
+	case "tag":
+		for _, tag := range e.Tags {
+			match = match || normalize(tag) == t.Value
+		}
+	case "style":
+		match = normalize(e.Style) == t.Value
+	case "has":
+		inst := drumbeat.InstrumentFromName(t.Value)
+		match = inst != drumbeat.Unknown && e.Has(inst)
+	case "format":
+		match = e.Format == t.Value
+	case "fingerprint":
+		match = e.Fingerprint == t.Value
+	}
+	if t.Op == "!=" {
+		return !match
+	}
+	return match
+}
+
+// normalize lowercases the text and replaces its dashes and underscores by
+// spaces so "Drum_and-Bass" matches "drum and bass".
+func normalize(s string) string {
+	return strings.ToLower(strings.NewReplacer("-", " ", "_", " ").Replace(s))
+}
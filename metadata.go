@@ -0,0 +1,180 @@
+package drumbeat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metadata describes the beat a pattern is part of, the patterns of a beat
+// share the same metadata. The formats storing a single set of metadata per
+// file (text, MIDI and PNG) use the metadata of the first pattern having some
+// and set it on all the patterns they read.
+type Metadata struct {
+	Title  string  `json:",omitempty"`
+	Author string  `json:",omitempty"`
+	BPM    float64 `json:",omitempty"`
+	// Swing is the MPC style swing percentage, see Swing.
+	Swing         float64 `json:",omitempty"`
+	TimeSignature TimeSignature
+	Style         string   `json:",omitempty"`
+	Tags          []string `json:",omitempty"`
+	// Source is the file or the origin of the beat.
+	Source string `json:",omitempty"`
+	// Extra holds free form values, its keys shouldn't be the name of one of
+	// the other fields.
+	Extra map[string]string `json:",omitempty"`
+}
+
+// TimeSignature is the meter of a beat, such as 4/4. The zero value means
+// that it's unknown.
+type TimeSignature struct {
+	Numerator   uint8
+	Denominator uint8
+}
+
+// String returns the time signature in the n/d notation, or an empty string
+// if it's unknown.
+func (ts TimeSignature) String() string {
+	if ts.Numerator == 0 || ts.Denominator == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", ts.Numerator, ts.Denominator)
+}
+
+// ParseTimeSignature parses a time signature such as 4/4 or 7/8, the
+// denominator must be a power of 2.
+func ParseTimeSignature(s string) (TimeSignature, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return TimeSignature{}, fmt.Errorf("invalid time signature %q", s)
+	}
+	num, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil || num == 0 {
+		return TimeSignature{}, fmt.Errorf("invalid time signature numerator in %q", s)
+	}
+	denom, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil || denom == 0 || denom&(denom-1) != 0 {
+		return TimeSignature{}, fmt.Errorf("invalid time signature denominator in %q", s)
+	}
+	return TimeSignature{Numerator: uint8(num), Denominator: uint8(denom)}, nil
+}
+
+// metadata keys used by the text based encodings.
+const (
+	metaTitle         = "title"
+	metaAuthor        = "author"
+	metaBPM           = "bpm"
+	metaSwing         = "swing"
+	metaTimeSignature = "time signature"
+	metaStyle         = "style"
+	metaTags          = "tags"
+	metaSource        = "source"
+)
+
+// metaPair is a metadata value encoded as text.
+type metaPair struct {
+	key   string
+	value string
+}
+
+// pairs returns the metadata set as text, the fields first followed by the
+// extra values sorted by key. The tags are comma separated.
+func (m *Metadata) pairs() []metaPair {
+	if m == nil {
+		return nil
+	}
+	pairs := []metaPair{}
+	add := func(key, value string) {
+		if value != "" {
+			pairs = append(pairs, metaPair{key, value})
+		}
+	}
+	add(metaTitle, m.Title)
+	add(metaAuthor, m.Author)
+	if m.BPM > 0 {
+		add(metaBPM, strconv.FormatFloat(m.BPM, 'f', -1, 64))
+	}
+	if m.Swing > 0 {
+		add(metaSwing, strconv.FormatFloat(m.Swing, 'f', -1, 64))
+	}
+	add(metaTimeSignature, m.TimeSignature.String())
+	add(metaStyle, m.Style)
+	add(metaTags, strings.Join(m.Tags, ","))
+	add(metaSource, m.Source)
+	keys := make([]string, 0, len(m.Extra))
+	for k := range m.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, m.Extra[k])
+	}
+	return pairs
+}
+
+// set parses a value encoded by pairs, unknown keys are added to the extra
+// values and invalid numbers are ignored.
+func (m *Metadata) set(key, value string) {
+	switch key {
+	case metaTitle:
+		m.Title = value
+	case metaAuthor:
+		m.Author = value
+	case metaBPM:
+		if bpm, err := strconv.ParseFloat(value, 64); err == nil && bpm > 0 {
+			m.BPM = bpm
+		}
+	case metaSwing:
+		if swing, err := strconv.ParseFloat(value, 64); err == nil && swing > 0 {
+			m.Swing = swing
+		}
+	case metaTimeSignature:
+		if ts, err := ParseTimeSignature(value); err == nil {
+			m.TimeSignature = ts
+		}
+	case metaStyle:
+		m.Style = value
+	case metaTags:
+		m.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+	case metaSource:
+		m.Source = value
+	default:
+		if m.Extra == nil {
+			m.Extra = map[string]string{}
+		}
+		m.Extra[key] = value
+	}
+}
+
+// beatMeta returns the metadata of the first pattern having some.
+func beatMeta(patterns []*Pattern) *Metadata {
+	for _, p := range patterns {
+		if p != nil && p.Meta != nil {
+			return p.Meta
+		}
+	}
+	return nil
+}
+
+// setMeta sets the metadata on all the patterns.
+func setMeta(patterns []*Pattern, m *Metadata) {
+	for _, p := range patterns {
+		if p != nil {
+			p.Meta = m
+		}
+	}
+}
+
+// roundBPM removes the rounding errors of tempos stored in microseconds per
+// beat.
+func roundBPM(bpm float64) float64 {
+	return math.Round(bpm*1000) / 1000
+}
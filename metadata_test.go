@@ -0,0 +1,130 @@
+package drumbeat
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mattetti/filebuffer"
+)
+
+func TestParseTimeSignature(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TimeSignature
+		wantErr bool
+	}{
+		{in: "4/4", want: TimeSignature{4, 4}},
+		{in: " 7/8 ", want: TimeSignature{7, 8}},
+		{in: "12/16", want: TimeSignature{12, 16}},
+		{in: "4", wantErr: true},
+		{in: "0/4", wantErr: true},
+		{in: "3/6", wantErr: true},
+		{in: "a/4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTimeSignature(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMetadataCodecs(t *testing.T) {
+	meta := &Metadata{
+		Title:         "Funky Drummer",
+		Author:        "Clyde Stubblefield",
+		BPM:           101.5,
+		Swing:         58,
+		TimeSignature: TimeSignature{7, 8},
+		Style:         "funk",
+		Tags:          []string{"break", "ghost notes"},
+		Source:        "crates/funky drummer.mid",
+		Extra:         map[string]string{"label": "King", "comment": "ça groove ♪"},
+	}
+	tests := []struct {
+		name   string
+		encode func(w *bytes.Buffer, patterns []*Pattern) error
+		decode func(r *bytes.Buffer) ([]*Pattern, error)
+	}{
+		{name: "gob",
+			encode: func(w *bytes.Buffer, patterns []*Pattern) error { return WriteTo(w, patterns...) },
+			decode: func(r *bytes.Buffer) ([]*Pattern, error) { return ReadFrom(r) },
+		},
+		{name: "json",
+			encode: func(w *bytes.Buffer, patterns []*Pattern) error { return WriteJSON(w, patterns...) },
+			decode: func(r *bytes.Buffer) ([]*Pattern, error) { return ReadJSON(r) },
+		},
+		{name: "text",
+			encode: func(w *bytes.Buffer, patterns []*Pattern) error {
+				_, err := w.WriteString(FormatString(patterns...))
+				return err
+			},
+			decode: func(r *bytes.Buffer) ([]*Pattern, error) { return NewFromString(One16, r.String()), nil },
+		},
+		{name: "midi",
+			encode: func(w *bytes.Buffer, patterns []*Pattern) error {
+				buf := filebuffer.New(nil)
+				if err := ToMIDI(buf, patterns...); err != nil {
+					return err
+				}
+				_, err := w.Write(buf.Buff.Bytes())
+				return err
+			},
+			decode: func(r *bytes.Buffer) ([]*Pattern, error) { return FromMIDI(r) },
+		},
+		{name: "png",
+			encode: func(w *bytes.Buffer, patterns []*Pattern) error { return SaveAsPNG(w, patterns) },
+			decode: func(r *bytes.Buffer) ([]*Pattern, error) { return FromPNG(r) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := NewFromString(One16, "[kick]\t{C1}\tx...x...;\n[snare]\t{D1}\t....x...")
+			setMeta(patterns, meta)
+			buf := &bytes.Buffer{}
+			if err := tt.encode(buf, patterns); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := tt.decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != 2 {
+				t.Fatalf("expected 2 patterns, got %d", len(decoded))
+			}
+			for i, p := range decoded {
+				if !reflect.DeepEqual(p.Meta, meta) {
+					t.Errorf("expected the metadata of pattern %d to be\n%+v\ngot\n%+v", i, meta, p.Meta)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatStringMetadata(t *testing.T) {
+	patterns := NewFromString(One16, `
+		@title=Amen; brother
+		@bpm=136
+		@tags=break, jungle
+		@mood=dark
+		[kick]	x...;
+		[snare]	....x...`)
+	want := &Metadata{Title: "Amen; brother", BPM: 136, Tags: []string{"break", "jungle"}, Extra: map[string]string{"mood": "dark"}}
+	if len(patterns) != 2 || patterns[0].Meta != patterns[1].Meta || !reflect.DeepEqual(patterns[0].Meta, want) {
+		t.Fatalf("expected 2 patterns sharing %+v, got %+v", want, patterns[0].Meta)
+	}
+	header := "@title=Amen; brother\n@bpm=136\n@tags=break,jungle\n@mood=dark\n[kick]\t"
+	if got := FormatString(patterns...); !strings.HasPrefix(got, header) {
+		t.Errorf("expected the text to start with\n%q\ngot\n%q", header, got)
+	}
+	if patterns := NewFromString(One16, "x..."); patterns[0].Meta != nil {
+		t.Errorf("expected no metadata, got %+v", patterns[0].Meta)
+	}
+}
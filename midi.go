@@ -1,10 +1,16 @@
 package drumbeat
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"io"
 	"math"
+	"sort"
+	"strings"
 
 	"github.com/go-audio/midi"
+	"github.com/mattetti/filebuffer"
 )
 
 // absolute representation of a pulse the duration of the event indicates
@@ -16,105 +22,195 @@ type absEv struct {
 	vel      uint8
 }
 
-// ToMIDI converts the passed patterns to a single MIDI file.
+// ToMIDI converts the passed patterns to a single MIDI file. The patterns are
+// expected to share the PPQN of the first one, see Beat to check it.
 func ToMIDI(w io.WriteSeeker, patterns ...*Pattern) error {
 	if len(patterns) < 1 || patterns[0] == nil {
 		return nil
 	}
 
-	// we are going to check if we have 2 patterns with their key at C-1 if
-	// that's the case, we'll assume that the keys weren't set.
-	areKeysSet := true
-	startingKey := midi.KeyInt("C", 1)
 	// Realign before converting
-	for i, t := range patterns {
+	for _, t := range patterns {
 		t.ReAlign()
-		if i > 0 {
-			if patterns[0].Key == 0 && t.Key == 0 {
-				areKeysSet = false
-			}
-		}
 	}
 
-	// FIXME: we shouldn't rely on the length of the first pattern
-	nbrSteps := len(patterns[0].Pulses)
+	// the encoder can't write all the meta events, they're added to the
+	// encoded track.
+	meta := beatMeta(patterns)
+	out := w
+	buf := filebuffer.New(nil)
+	if meta != nil {
+		out = buf
+	}
+
 	ppq := patterns[0].PPQN
-	e := midi.NewEncoder(w, 0, ppq)
+	e := midi.NewEncoder(out, 0, ppq)
 
-	trackState := map[int]bool{}
+	// FIXME: use the actual pattern duration and the length of all the
+	// patterns instead of the first one.
+	currentStepDuration := uint64(ppq) / 4
+	evs, end := noteEvents(patterns, len(patterns[0].Pulses), func(*Pattern) uint64 {
+		return currentStepDuration
+	})
 
 	tr := e.NewTrack()
-	var delta uint32
-
-	// FIXME: use the actual pattern duration.
-	currentStepDuration := uint32(ppq) / 4
-	// loop through all the steps, one step at a time and inject
-	// all track states inside the same channel.
-	for i := 0; i < nbrSteps; i++ {
-		if i > 0 {
-			// FIXME: don't automatically quantize the pulses
-			delta += currentStepDuration
-		}
-		for n, t := range patterns {
-			notePitch := t.Key
-			if !areKeysSet && notePitch == 0 {
-				notePitch = startingKey + n
-			}
-			var stepVal *Pulse
-			// guard
-			if len(t.Pulses) > i {
-				stepVal = t.Pulses[i]
-			}
+	writeNoteEvs(tr, evs, end)
 
-			// empty step: stop playing note if needed
-			if stepVal == nil {
-				if on, ok := trackState[notePitch]; ok && on {
-					// note is playing,let's stop it
-					tr.AddAfterDelta(delta, midi.NoteOff(0, notePitch))
-					trackState[notePitch] = false
-					delta = 0.0
-				}
-				continue
-			}
+	if err := e.Write(); err != nil || meta == nil {
+		return err
+	}
+	data, err := insertMetaEvents(buf.Buff.Bytes(), meta)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
 
-			// we have a pulse!
+// meta event types
+const (
+	metaEvText          = 0x01
+	metaEvCopyright     = 0x02
+	metaEvTrackName     = 0x03
+	metaEvTempo         = 0x51
+	metaEvTimeSignature = 0x58
+)
 
-			tr.AddAfterDelta(delta, midi.NoteOn(0, notePitch, int(stepVal.Velocity)))
-			// mark note as playing
-			trackState[notePitch] = true
-			delta = 0.0
-		}
+// insertMetaEvents adds the metadata to the start of the first track of an
+// encoded MIDI file: the title is the track name, the author is the
+// copyright, the BPM and time signature use their meta events and the other
+// values are text events in the key=value format.
+func insertMetaEvents(data []byte, meta *Metadata) ([]byte, error) {
+	const trackStart = 14 + 8
+	if len(data) < trackStart || string(data[14:18]) != "MTrk" {
+		return nil, errors.New("unexpected MIDI encoding")
 	}
-
-	var wroteLastStep bool
-	for pitch, on := range trackState {
-		if on {
-			tr.AddAfterDelta(delta+currentStepDuration, midi.NoteOff(0, pitch))
-			delta = 0
-			wroteLastStep = true
+	evs := &bytes.Buffer{}
+	add := func(typ byte, payload []byte) {
+		evs.Write([]byte{0x00, 0xFF, typ})
+		evs.Write(midi.EncodeVarint(uint32(len(payload))))
+		evs.Write(payload)
+	}
+	for _, pair := range meta.pairs() {
+		switch pair.key {
+		case metaTitle:
+			add(metaEvTrackName, []byte(pair.value))
+		case metaAuthor:
+			add(metaEvCopyright, []byte(pair.value))
+		case metaBPM:
+			add(metaEvTempo, midi.Uint24(uint32(math.Round(60000000/meta.BPM))))
+		case metaTimeSignature:
+			// 24 MIDI clocks per click and 8 32nd notes per beat
+			denom := byte(0)
+			for d := meta.TimeSignature.Denominator; d > 1; d /= 2 {
+				denom++
+			}
+			add(metaEvTimeSignature, []byte{meta.TimeSignature.Numerator, denom, 24, 8})
+		default:
+			add(metaEvText, []byte(pair.key+"="+pair.value))
 		}
 	}
 
-	// end the track after the last step
-	if wroteLastStep {
-		tr.Add(0, midi.EndOfTrack())
-	} else {
-		tr.AddAfterDelta(delta+currentStepDuration, midi.EndOfTrack())
+	// the encoder starts the tracks with a 4/4 time signature which would
+	// override ours
+	at := trackStart
+	if bytes.HasPrefix(data[at:], []byte{0x00, 0xFF, metaEvTimeSignature, 0x04}) {
+		at += 8
 	}
+	out := make([]byte, 0, len(data)+evs.Len())
+	out = append(out, data[:at]...)
+	out = append(out, evs.Bytes()...)
+	out = append(out, data[at:]...)
+	size := binary.BigEndian.Uint32(data[18:22])
+	binary.BigEndian.PutUint32(out[18:22], size+uint32(evs.Len()))
+	return out, nil
+}
 
-	return e.Write()
+// writeNoteEvs sorts the events and adds them to the track followed by an end
+// of track event at the end tick (or after the last event).
+func writeNoteEvs(tr *midi.Track, evs []noteEv, end uint64) {
+	sortNoteEvs(evs)
+	var last uint64
+	for _, ev := range evs {
+		if ev.on {
+			tr.AddAfterDelta(uint32(ev.tick-last), midi.NoteOn(0, ev.key, int(ev.vel)))
+		} else {
+			tr.AddAfterDelta(uint32(ev.tick-last), midi.NoteOff(0, ev.key))
+		}
+		last = ev.tick
+	}
+	if end < last {
+		end = last
+	}
+	tr.AddAfterDelta(uint32(end-last), midi.EndOfTrack())
 }
 
 // FromMIDI converts the content of a MIDI file into drum beat patterns. Note
 // that this is for drum patterns only, expect the unexpected if you use non
 // drum sequences.
 func FromMIDI(r io.Reader) ([]*Pattern, error) {
+	absEvs, totalDuration, ppqn, meta, err := midiEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	patterns := []*Pattern{}
+
+	// 1/16th
+	gridRes := uint32(ppqn) / 4
+
+	pitches := make([]int, 0, len(absEvs))
+	for pitch := range absEvs {
+		pitches = append(pitches, pitch)
+	}
+	sort.Ints(pitches)
+
+	for _, pitch := range pitches {
+		events := absEvs[pitch]
+		if len(events) < 1 {
+			continue
+		}
+
+		pat := &Pattern{
+			Name: midi.NoteToName(pitch),
+			Key:  pitch,
+			PPQN: ppqn,
+			Grid: One16,
+		}
+
+		nbrSteps := math.Ceil(float64(totalDuration) / float64(gridRes))
+		pat.Pulses = make(Pulses, int(nbrSteps))
+
+		// TODO: quantize
+		for i := range pat.Pulses {
+			start := uint64(i) * uint64(gridRes)
+			for _, e := range events {
+				if e.start >= start && e.start < start+uint64(gridRes) {
+					pat.Pulses[i] = &Pulse{
+						Ticks:    start,
+						Duration: uint16(gridRes),
+						Velocity: e.vel,
+					}
+					break
+				}
+			}
+		}
+
+		patterns = append(patterns, pat)
+	}
+	setMeta(patterns, meta)
+
+	return patterns, nil
+}
+
+// midiEvents decodes the MIDI content and returns the unquantized note events
+// per pitch, the total duration in ticks, the PPQN and the metadata of the
+// file.
+func midiEvents(r io.Reader) (map[int][]absEv, uint32, uint16, *Metadata, error) {
 	dec := midi.NewDecoder(r)
 	if err := dec.Parse(); err != nil {
-		return nil, err
+		return nil, 0, 0, nil, err
 	}
 	totalDuration := uint32(0) // in ticks
-	patterns := []*Pattern{}
 
 	absEvs := map[int][]absEv{}
 	curEvsStart := map[string]*midi.Event{}
@@ -156,7 +252,7 @@ func FromMIDI(r io.Reader) ([]*Pattern, error) {
 				absEvs[pitch] = append(absEvs[pitch],
 					absEv{
 						start:    uint64(curEvsStart[n].AbsTicks),
-						duration: totalDuration - uint32(ev.AbsTicks),
+						duration: totalDuration - uint32(curEvsStart[n].AbsTicks),
 						vel:      curEvsStart[n].Velocity,
 					})
 				curEvsStart[n] = nil
@@ -164,41 +260,63 @@ func FromMIDI(r io.Reader) ([]*Pattern, error) {
 		}
 	}
 
-	// 1/16th
-	gridRes := uint32(dec.TicksPerQuarterNote) / 4
-
-	for pitch, events := range absEvs {
-		if len(events) < 1 {
-			continue
-		}
-
-		pat := &Pattern{
-			Name: midi.NoteToName(pitch),
-			Key:  pitch,
-			PPQN: dec.TicksPerQuarterNote,
-			Grid: One16,
-		}
-
-		nbrSteps := math.Ceil(float64(totalDuration) / float64(gridRes))
-		pat.Pulses = make(Pulses, int(nbrSteps))
+	return absEvs, totalDuration, dec.TicksPerQuarterNote, midiMeta(dec.Tracks), nil
+}
 
-		// TODO: quantize
-		for i := range pat.Pulses {
-			start := uint64(i) * uint64(gridRes)
-			for _, e := range events {
-				if e.start >= start && e.start < start+uint64(gridRes) {
-					pat.Pulses[i] = &Pulse{
-						Ticks:    start,
-						Duration: uint16(gridRes),
-						Velocity: e.vel,
+// midiMeta reads the metadata written by ToMIDI from the meta events. The
+// text events which aren't key=value pairs are joined in the "text" extra
+// value. The time signature is ignored if it's the only metadata and is 4/4
+// since most encoders write it by default.
+func midiMeta(tracks []*midi.Track) *Metadata {
+	meta := &Metadata{}
+	var found bool
+	var ts TimeSignature
+	var texts []string
+	for _, t := range tracks {
+		for _, ev := range t.Events {
+			if ev.MsgType != midi.EventByteMap["Meta"] || ev.MsgChan != 0xF {
+				continue
+			}
+			switch ev.Cmd {
+			case metaEvTrackName:
+				if title := strings.TrimRight(ev.SeqTrackName, "\x00"); title != "" && meta.Title == "" {
+					meta.Title, found = title, true
+				}
+			case metaEvCopyright:
+				if author := strings.TrimRight(ev.Copyright, "\x00"); author != "" {
+					meta.Author, found = author, true
+				}
+			case metaEvTempo:
+				if ev.MsPerQuartNote > 0 && meta.BPM == 0 {
+					meta.BPM, found = roundBPM(60000000/float64(ev.MsPerQuartNote)), true
+				}
+			case metaEvTimeSignature:
+				if ev.TimeSignature != nil && ev.TimeSignature.Denominator < 8 {
+					ts = TimeSignature{
+						Numerator:   ev.TimeSignature.Numerator,
+						Denominator: uint8(ev.TimeSignature.Denum()),
 					}
-					break
 				}
+			case metaEvText:
+				text := strings.TrimRight(ev.Text, "\x00")
+				if kv := strings.SplitN(text, "=", 2); len(kv) == 2 && kv[0] != "" {
+					meta.set(kv[0], kv[1])
+				} else if text != "" {
+					texts = append(texts, text)
+				}
+				found = true
 			}
 		}
-
-		patterns = append(patterns, pat)
 	}
-
-	return patterns, nil
+	if len(texts) > 0 {
+		meta.set("text", strings.Join(texts, "\n"))
+	}
+	if ts != (TimeSignature{4, 4}) || found {
+		meta.TimeSignature = ts
+		found = found || ts != (TimeSignature{})
+	}
+	if !found {
+		return nil
+	}
+	return meta
 }
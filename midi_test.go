@@ -143,3 +143,74 @@ func TestToMIDI(t *testing.T) {
 	})
 
 }
+
+func TestToMIDI_nudge(t *testing.T) {
+	patterns := NewFromString(One16, "x...x...x...x...")
+	patterns[0].Pulses[4].Nudge = -3
+	patterns[0].Pulses[8].Nudge = 5
+	buf := filebuffer.New(nil)
+	if err := ToMIDI(buf, patterns...); err != nil {
+		t.Fatalf("ToMIDI() error = %v", err)
+	}
+	buf.Seek(0, io.SeekStart)
+	absEvs, _, _, _, err := midiEvents(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{0, 93, 197, 288}
+	evs := absEvs[patterns[0].Key]
+	if len(evs) != len(want) {
+		t.Fatalf("expected %d notes, got %d", len(want), len(evs))
+	}
+	for i, ev := range evs {
+		if ev.start != want[i] {
+			t.Errorf("[%d] expected note to start at %d, got %d", i, want[i], ev.start)
+		}
+	}
+}
+
+func TestToMIDI_subHits(t *testing.T) {
+	patterns := NewFromString(One16, "x(r3)...x(flam)...")
+	buf := filebuffer.New(nil)
+	if err := ToMIDI(buf, patterns...); err != nil {
+		t.Fatalf("ToMIDI() error = %v", err)
+	}
+	buf.Seek(0, io.SeekStart)
+	absEvs, _, _, _, err := midiEvents(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{0, 8, 16, 90, 96}
+	evs := absEvs[patterns[0].Key]
+	if len(evs) != len(want) {
+		t.Fatalf("expected %d notes, got %d", len(want), len(evs))
+	}
+	for i, ev := range evs {
+		if ev.start != want[i] {
+			t.Errorf("[%d] expected note to start at %d, got %d", i, want[i], ev.start)
+		}
+	}
+}
+
+func TestToMIDI_chokeGroup(t *testing.T) {
+	patterns := NewFromString(One16, "[hihat open]{A#1}xxx.....;[hihat]{F#1}..x..x..")
+	patterns[0].ChokeGroup, patterns[1].ChokeGroup = 1, 1
+	buf := filebuffer.New(nil)
+	if err := ToMIDI(buf, patterns...); err != nil {
+		t.Fatalf("ToMIDI() error = %v", err)
+	}
+	buf.Seek(0, io.SeekStart)
+	absEvs, _, _, _, err := midiEvents(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := absEvs[patterns[0].Key]
+	if len(open) != 3 {
+		t.Fatalf("expected 3 open hihat notes, got %d", len(open))
+	}
+	// the last open hihat was triggered at the same time as the closed hihat
+	// so it isn't choked and is played until the end of its step.
+	if last := open[2]; last.start != 48 || last.start+uint64(last.duration) != 72 {
+		t.Errorf("expected the last open hihat to play from 48 to 72, got %d to %d", last.start, last.start+uint64(last.duration))
+	}
+}
@@ -0,0 +1,100 @@
+// Package osc sends drum patterns as Open Sound Control messages.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Message is an OSC message. The supported argument types are int32, float32,
+// string and []byte (blob).
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+// MarshalBinary encodes the message.
+func (m Message) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeString(buf, m.Address)
+	tags := []byte{','}
+	args := &bytes.Buffer{}
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(args, binary.BigEndian, v)
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(args, binary.BigEndian, math.Float32bits(v))
+		case string:
+			tags = append(tags, 's')
+			writeString(args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			pad(args, len(v))
+		default:
+			return nil, fmt.Errorf("unsupported OSC argument type %T", arg)
+		}
+	}
+	writeString(buf, string(tags))
+	buf.Write(args.Bytes())
+	return buf.Bytes(), nil
+}
+
+// Bundle is a group of messages to be processed at the same time.
+type Bundle struct {
+	// Time is when the messages should be processed, the zero time means
+	// immediately.
+	Time     time.Time
+	Messages []Message
+}
+
+// MarshalBinary encodes the bundle.
+func (b Bundle) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeString(buf, "#bundle")
+	binary.Write(buf, binary.BigEndian, TimeTag(b.Time))
+	for _, m := range b.Messages {
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(buf, binary.BigEndian, int32(len(data)))
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// secondsFrom1900To1970 is the offset between the NTP and Unix epochs.
+const secondsFrom1900To1970 = 2208988800
+
+// TimeTag converts a time to an OSC time tag, the zero time is converted to
+// the special "immediately" time tag.
+func TimeTag(t time.Time) uint64 {
+	if t.IsZero() {
+		return 1
+	}
+	secs := uint64(t.Unix() + secondsFrom1900To1970)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// writeString writes an OSC string: null terminated and padded to 4 bytes.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.WriteByte(0)
+	pad(buf, len(s)+1)
+}
+
+// pad adds the null bytes needed to align data of length n to 4 bytes.
+func pad(buf *bytes.Buffer, n int) {
+	for ; n%4 != 0; n++ {
+		buf.WriteByte(0)
+	}
+}
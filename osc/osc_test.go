@@ -0,0 +1,68 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMessage_MarshalBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		want    []byte
+		wantErr bool
+	}{
+		{name: "no args",
+			msg:  Message{Address: "/kick"},
+			want: []byte{'/', 'k', 'i', 'c', 'k', 0, 0, 0, ',', 0, 0, 0}},
+		{name: "int",
+			msg:  Message{Address: "/kit", Args: []interface{}{int32(100)}},
+			want: []byte{'/', 'k', 'i', 't', 0, 0, 0, 0, ',', 'i', 0, 0, 0, 0, 0, 100}},
+		{name: "float, string and blob",
+			msg: Message{Address: "/a", Args: []interface{}{float32(1), "hh", []byte{1, 2, 3, 4, 5}}},
+			want: []byte{'/', 'a', 0, 0, ',', 'f', 's', 'b', 0, 0, 0, 0,
+				0x3F, 0x80, 0, 0,
+				'h', 'h', 0, 0,
+				0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0}},
+		{name: "unsupported", msg: Message{Address: "/a", Args: []interface{}{42}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg.MarshalBinary()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("expected % X\ngot % X", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBundle_MarshalBinary(t *testing.T) {
+	b := Bundle{
+		Time:     time.Unix(1, int64(time.Second/2)),
+		Messages: []Message{{Address: "/a"}},
+	}
+	got, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{'#', 'b', 'u', 'n', 'd', 'l', 'e', 0,
+		0x83, 0xAA, 0x7E, 0x81, 0x80, 0, 0, 0,
+		0, 0, 0, 8,
+		'/', 'a', 0, 0, ',', 0, 0, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected % X\ngot % X", want, got)
+	}
+}
+
+func TestTimeTag(t *testing.T) {
+	if tag := TimeTag(time.Time{}); tag != 1 {
+		t.Errorf("expected the zero time to be immediate, got %d", tag)
+	}
+	if tag := TimeTag(time.Unix(0, 0)); tag != secondsFrom1900To1970<<32 {
+		t.Errorf("expected the unix epoch to be %d, got %d", uint64(secondsFrom1900To1970)<<32, tag)
+	}
+}
@@ -0,0 +1,132 @@
+package osc
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/drumbeat/sequencer"
+)
+
+// DefaultAddress is the address scheme used by the senders.
+const DefaultAddress = "/drumbeat/{name}"
+
+// Sender sends a message per pulse, made of the pattern's address and the
+// pulse's velocity as an int32. The messages are wrapped in timestamped
+// bundles so the receivers can schedule them. A sender can be used as the
+// sink of a sequencer.
+type Sender struct {
+	// Address is the address scheme of the messages, {name}, {key} and
+	// {instrument} are replaced by the pattern's name, MIDI key and
+	// instrument. Defaults to DefaultAddress.
+	Address string
+	// Latency is added to the time of the events so the receivers get them
+	// before they are due.
+	Latency time.Duration
+
+	w io.Writer
+}
+
+// NewSender returns a sender writing a packet per bundle to w.
+func NewSender(w io.Writer) *Sender {
+	return &Sender{Address: DefaultAddress, w: w}
+}
+
+// Dial returns a sender sending the bundles over UDP to the address
+// (host:port).
+func Dial(addr string) (*Sender, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewSender(conn), nil
+}
+
+// Close closes the underlying writer if it's a closer.
+func (s *Sender) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Send sends the note ons played by a sequencer, note offs are ignored.
+func (s *Sender) Send(ev sequencer.Event) error {
+	if !ev.On {
+		return nil
+	}
+	return s.send(ev.Time.Add(s.Latency), s.Message(ev.Name, ev.Key, ev.Velocity))
+}
+
+// SendTimeline sends all the pulses of the patterns at once, each bundle is
+// timestamped with the time at which the pulse should be played when the
+// patterns start at the passed time.
+func (s *Sender) SendTimeline(start time.Time, bpm float64, patterns ...*drumbeat.Pattern) error {
+	if bpm <= 0 {
+		return drumbeat.ErrInvalidTempo
+	}
+	events, _ := drumbeat.Timeline(patterns...)
+	ppqn := drumbeat.DefaultPPQN
+	for _, p := range patterns {
+		if p != nil && p.PPQN > 0 {
+			ppqn = p.PPQN
+			break
+		}
+	}
+	tick := float64(time.Minute) / (bpm * float64(ppqn))
+	for _, ev := range events {
+		if !ev.On {
+			continue
+		}
+		p := patterns[ev.Pattern]
+		at := start.Add(s.Latency + time.Duration(float64(ev.Ticks)*tick))
+		if err := s.send(at, s.Message(p.Name, ev.Key, ev.Velocity)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Message returns the message triggering the note of a pattern.
+func (s *Sender) Message(name string, key int, velocity uint8) Message {
+	if name == "" {
+		name = strconv.Itoa(key)
+	}
+	inst := drumbeat.InstrumentFromName(name)
+	if inst == drumbeat.Unknown {
+		inst = drumbeat.GMDrumMap[key]
+	}
+	addr := s.Address
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	addr = strings.NewReplacer(
+		"{name}", addressPart(name),
+		"{key}", strconv.Itoa(key),
+		"{instrument}", addressPart(string(inst)),
+	).Replace(addr)
+	return Message{Address: addr, Args: []interface{}{int32(velocity)}}
+}
+
+func (s *Sender) send(at time.Time, m Message) error {
+	data, err := Bundle{Time: at, Messages: []Message{m}}.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(data)
+	return err
+}
+
+// addressPart replaces the characters that aren't allowed in an OSC address.
+func addressPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '#', '*', ',', '/', '?', '[', ']', '{', '}':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,92 @@
+package osc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+	"github.com/mattetti/drumbeat/sequencer"
+)
+
+// listen returns a local UDP listener and a sender connected to it.
+func listen(t *testing.T) (net.PacketConn, *Sender) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Dial(conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	return conn, s
+}
+
+func receive(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func bundle(t *testing.T, at time.Time, addr string, vel int32) []byte {
+	t.Helper()
+	data, err := Bundle{Time: at, Messages: []Message{{Address: addr, Args: []interface{}{vel}}}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSender_SendTimeline(t *testing.T) {
+	conn, s := listen(t)
+	defer conn.Close()
+	defer s.Close()
+
+	patterns := drumbeat.NewFromString(drumbeat.One4, `
+		[kick]	{C1}	x.x.;
+		[hihat closed]	{F#1}	.x..`)
+	start := time.Unix(1554076800, 0)
+	if err := s.SendTimeline(start, 60, patterns...); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{
+		bundle(t, start, "/drumbeat/kick", 90),
+		bundle(t, start.Add(time.Second), "/drumbeat/hihat_closed", 90),
+		bundle(t, start.Add(2*time.Second), "/drumbeat/kick", 90),
+	}
+	for i, w := range want {
+		if got := receive(t, conn); !bytes.Equal(got, w) {
+			t.Errorf("[%d] expected % X\ngot % X", i, w, got)
+		}
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	conn, s := listen(t)
+	defer conn.Close()
+	defer s.Close()
+	s.Address = "/kit/{instrument}/{key}"
+	s.Latency = 100 * time.Millisecond
+
+	now := time.Unix(1554076800, 0)
+	off := sequencer.Event{NoteEvent: drumbeat.NoteEvent{Key: 38}, Name: "sd", Time: now}
+	on := sequencer.Event{NoteEvent: drumbeat.NoteEvent{Key: 38, Velocity: 110, On: true}, Name: "sd", Time: now}
+	for _, ev := range []sequencer.Event{off, on} {
+		if err := s.Send(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the note off isn't sent
+	want := bundle(t, now.Add(s.Latency), "/kit/snare/38", 110)
+	if got := receive(t, conn); !bytes.Equal(got, want) {
+		t.Errorf("expected % X\ngot % X", want, got)
+	}
+}
@@ -17,7 +17,28 @@ var (
 // steps fit in a bar. Default velocity is 0.9
 //
 // Multiple patterns can be provided if separated by a semi colon: `;`.
+//
+// Comma separated modifiers can be added between parenthesis after a pulse:
+//
+//	x(50%)        trig probability, x(0%) is never played
+//	x(1:2)        trig condition, see Condition
+//	x(r3)         ratchet, 3 hits within the step
+//	x(r4+30)      ratchet with a velocity ramp, up by 30 over the 4 hits
+//	x(flam)       flam, x(flam:8) sets the grace note offset to 8 ticks
+//	x(drag)       drag, x(drag:8) sets the grace notes offset to 8 ticks
+//	x(g50%)       gate of 50% of the pulse duration
+//	x(g12)        gate of 12 ticks
+//	x(trig)       trigger gate, very short note
+//
+// Modifiers placed before the first step set the default gate of the pattern
+// and a `=` after a pulse ties the next step to it: `x===` is held for 4 steps.
+// The other characters, such as `.`, `-` or `_`, are rests.
+//
+// Lines starting with `@` set the metadata shared by the patterns, such as
+// `@bpm=96`, `@time signature=4/4` or `@tags=funk,break`. The keys which
+// aren't Metadata fields are added to its extra values.
 func NewFromString(grid GridRes, str string) []*Pattern {
+	str, meta := parseMetaLines(str)
 	// support multiplexing of patterns by separating them by a `;`
 	patStrs := strings.Split(str, ";")
 
@@ -40,11 +61,11 @@ func NewFromString(grid GridRes, str string) []*Pattern {
 		keyEndIDX := strings.IndexByte(patStr, '}')
 		if keyStartIDX != -1 && keyEndIDX != -1 {
 			keyStr := patStr[keyStartIDX+1 : keyEndIDX]
-			if l := len(keyStr); l > 1 {
-				oct, err := strconv.Atoi(string(keyStr[l-1]))
+			// the octave can be negative, down to C-2
+			if i := strings.IndexAny(keyStr, "-0123456789"); i > 0 {
+				oct, err := strconv.Atoi(keyStr[i:])
 				if err == nil {
-					keyLetter := string(keyStr[:l-1])
-					pat.Key = midi.KeyInt(keyLetter, oct)
+					pat.Key = midi.KeyInt(keyStr[:i], oct)
 				}
 			}
 			patStr = patStr[:keyStartIDX] + patStr[keyEndIDX+1:]
@@ -52,22 +73,288 @@ func NewFromString(grid GridRes, str string) []*Pattern {
 
 		patStr = patStrReplacer.Replace(patStr)
 
-		pat.Pulses = make(Pulses, len(patStr))
-		for i, r := range strings.ToLower(patStr) {
-			if r == 'x' {
+		steps, patMods := parseSteps(patStr)
+		for _, mod := range patMods {
+			if g, ok := parseGate(mod); ok {
+				pat.Gate = g
+			}
+		}
+		pat.Pulses = make(Pulses, len(steps))
+		var held *Pulse
+		for i, st := range steps {
+			switch {
+			case st.hit && neverPlayed(st.mods):
+				// a 0% chance trig never plays, it's a rest.
+				held = nil
+			case st.hit:
 				pat.Pulses[i] = &Pulse{
 					Ticks:    gridRes * uint64(i),
 					Velocity: 90,
 					Duration: uint16(gridRes),
 				}
+				for _, mod := range st.mods {
+					applyModifier(pat.Pulses[i], mod)
+				}
+				held = pat.Pulses[i]
+			case st.tie && held != nil:
+				held.Duration += uint16(gridRes)
+			default:
+				held = nil
 			}
 		}
 		patterns = append(patterns, pat)
 	}
+	setMeta(patterns, meta)
 
 	return patterns
 }
 
+// parseMetaLines removes the metadata lines from the text notation and
+// returns the metadata they define, if any.
+func parseMetaLines(str string) (string, *Metadata) {
+	if !strings.Contains(str, "@") {
+		return str, nil
+	}
+	var meta *Metadata
+	lines := strings.Split(str, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "@") {
+			kept = append(kept, line)
+			continue
+		}
+		kv := strings.SplitN(trimmed[1:], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if meta == nil {
+			meta = &Metadata{}
+		}
+		meta.set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return strings.Join(kept, "\n"), meta
+}
+
+// FormatString converts the patterns to the text notation read by
+// NewFromString. The bars are separated by tabs and the pulse modifiers are
+// kept but the velocities and nudges can't be expressed and are lost. Keys
+// at 0 (C-2) mean no key and are left out. The metadata is written as `@key=value` lines before
+// the patterns.
+func FormatString(patterns ...*Pattern) string {
+	var sb strings.Builder
+	for _, pair := range beatMeta(patterns).pairs() {
+		sb.WriteString("@" + pair.key + "=" + strings.NewReplacer("\n", " ", "\r", " ").Replace(pair.value) + "\n")
+	}
+	for n, p := range patterns {
+		if p == nil {
+			continue
+		}
+		if n > 0 {
+			sb.WriteString(";\n")
+		}
+		if p.Name != "" {
+			sb.WriteString("[" + p.Name + "]\t")
+		}
+		if p.Key > 0 {
+			sb.WriteString("{" + midi.NoteToName(p.Key) + "}\t")
+		}
+		if mod := formatGate(p.Gate); mod != "" {
+			sb.WriteString("(" + mod + ")")
+		}
+		stepSize := p.StepSize()
+		barSteps := int(p.Grid.StepsInBeat() * 4)
+		ties := 0
+		for i, pulse := range p.Pulses {
+			if i > 0 && i%barSteps == 0 {
+				sb.WriteByte('\t')
+			}
+			switch {
+			case pulse != nil:
+				sb.WriteByte('x')
+				if mods := pulseModifiers(pulse); len(mods) > 0 {
+					sb.WriteString("(" + strings.Join(mods, ",") + ")")
+				}
+				ties = 0
+				if stepSize > 0 && uint64(pulse.Duration) >= 2*stepSize {
+					ties = int(uint64(pulse.Duration)/stepSize) - 1
+				}
+			case ties > 0:
+				sb.WriteByte('=')
+				ties--
+			default:
+				sb.WriteByte('.')
+			}
+		}
+	}
+	return sb.String()
+}
+
+// pulseModifiers returns the text modifiers describing the pulse.
+func pulseModifiers(pulse *Pulse) []string {
+	mods := []string{}
+	if pulse.Probability > 0 && pulse.Probability < 100 {
+		mods = append(mods, strconv.Itoa(int(pulse.Probability))+"%")
+	}
+	if pulse.Condition != CondNone {
+		mods = append(mods, string(pulse.Condition))
+	}
+	if pulse.Ratchets > 1 {
+		mod := "r" + strconv.Itoa(int(pulse.Ratchets))
+		if pulse.RatchetRamp > 0 {
+			mod += "+"
+		}
+		if pulse.RatchetRamp != 0 {
+			mod += strconv.Itoa(int(pulse.RatchetRamp))
+		}
+		mods = append(mods, mod)
+	}
+	if pulse.Ornament != NoOrnament {
+		mod := string(pulse.Ornament)
+		if pulse.GraceOffset > 0 {
+			mod += ":" + strconv.Itoa(int(pulse.GraceOffset))
+		}
+		mods = append(mods, mod)
+	}
+	if mod := formatGate(pulse.Gate); mod != "" {
+		mods = append(mods, mod)
+	}
+	return mods
+}
+
+// formatGate returns the text modifier of a gate, the default gate doesn't
+// have one.
+func formatGate(g Gate) string {
+	switch g.Mode {
+	case GateTicks:
+		return "g" + strconv.Itoa(int(g.Value))
+	case GatePercent:
+		return "g" + strconv.Itoa(int(g.Value)) + "%"
+	case GateTrigger:
+		return "trig"
+	}
+	return ""
+}
+
+// stepToken is a step parsed from the text notation.
+type stepToken struct {
+	hit  bool
+	tie  bool
+	mods []string
+}
+
+// neverPlayed reports if the modifiers of a step give it a 0% probability.
+func neverPlayed(mods []string) bool {
+	for _, mod := range mods {
+		if !strings.HasSuffix(mod, "%") {
+			continue
+		}
+		if prob, err := strconv.Atoi(strings.TrimSuffix(mod, "%")); err == nil && prob == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSteps splits the text notation in steps, any character other than `x`
+// and `=` is a rest. The modifiers found before the first step are returned
+// separately.
+func parseSteps(str string) (steps []stepToken, patMods []string) {
+	steps = []stepToken{}
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '(':
+			end := strings.IndexByte(str[i:], ')')
+			if end == -1 {
+				return steps, patMods
+			}
+			for _, mod := range strings.Split(str[i+1:i+end], ",") {
+				mod = strings.TrimSpace(mod)
+				if n := len(steps); n > 0 {
+					steps[n-1].mods = append(steps[n-1].mods, mod)
+				} else {
+					patMods = append(patMods, mod)
+				}
+			}
+			i += end
+		case 'x', 'X':
+			steps = append(steps, stepToken{hit: true})
+		case '=':
+			steps = append(steps, stepToken{tie: true})
+		default:
+			steps = append(steps, stepToken{})
+		}
+	}
+	return steps, patMods
+}
+
+// parseGate parses a gate modifier: `g50%`, `g12` or `trig`.
+func parseGate(mod string) (Gate, bool) {
+	mod = strings.ToLower(mod)
+	if mod == "trig" {
+		return Gate{Mode: GateTrigger}, true
+	}
+	if !strings.HasPrefix(mod, "g") {
+		return Gate{}, false
+	}
+	mode := GateTicks
+	if strings.HasSuffix(mod, "%") {
+		mode = GatePercent
+		mod = strings.TrimSuffix(mod, "%")
+	}
+	v, err := strconv.Atoi(mod[1:])
+	if err != nil || v < 1 || v > math.MaxUint16 {
+		return Gate{}, false
+	}
+	return Gate{Mode: mode, Value: uint16(v)}, true
+}
+
+// applyModifier sets the pulse property described by the text modifier.
+// Invalid modifiers are ignored.
+func applyModifier(pulse *Pulse, mod string) {
+	mod = strings.ToLower(mod)
+	switch {
+	case mod == "trig" || strings.HasPrefix(mod, "g"):
+		if g, ok := parseGate(mod); ok {
+			pulse.Gate = g
+		}
+		return
+	case strings.HasSuffix(mod, "%"):
+		if prob, err := strconv.Atoi(strings.TrimSuffix(mod, "%")); err == nil && prob > 0 && prob <= 100 {
+			pulse.Probability = uint8(prob)
+		}
+		return
+	case strings.HasPrefix(mod, "r") && len(mod) > 1 && mod[1] >= '0' && mod[1] <= '9':
+		count, ramp := mod[1:], ""
+		if idx := strings.IndexAny(count, "+-"); idx != -1 {
+			count, ramp = count[:idx], count[idx:]
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || n > 255 {
+			return
+		}
+		pulse.Ratchets = uint8(n)
+		if r, err := strconv.Atoi(ramp); err == nil && r >= -128 && r <= 127 {
+			pulse.RatchetRamp = int8(r)
+		}
+		return
+	case strings.HasPrefix(mod, string(Flam)) || strings.HasPrefix(mod, string(Drag)):
+		parts := strings.SplitN(mod, ":", 2)
+		if orn := Ornament(parts[0]); orn == Flam || orn == Drag {
+			pulse.Ornament = orn
+			if len(parts) == 2 {
+				if offset, err := strconv.Atoi(parts[1]); err == nil && offset > 0 && offset <= math.MaxUint16 {
+					pulse.GraceOffset = uint16(offset)
+				}
+			}
+		}
+		return
+	}
+	if cond, err := ParseCondition(mod); err == nil {
+		pulse.Condition = cond
+	}
+}
+
 // Pattern represent the content of a drum pattern/beat.
 type Pattern struct {
 	// Name of the pattern or instrument
@@ -81,7 +368,14 @@ type Pattern struct {
 	// PPQN is the amount of ticks per quarter note.
 	PPQN uint16
 	// Grid is the resolution of the pattern
-	Grid       GridRes
+	Grid GridRes
+	// ChokeGroup groups patterns cutting each other when played, such as open
+	// and closed hihats. 0 means no group.
+	ChokeGroup int
+	// Gate is the default length of the notes of the pattern.
+	Gate Gate
+	// Meta describes the beat the pattern is part of.
+	Meta       *Metadata `json:",omitempty"`
 	countCache int
 }
 
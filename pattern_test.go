@@ -367,3 +367,67 @@ func TestPattern_ReAlign(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatString(t *testing.T) {
+	tests := []struct {
+		name string
+		grid GridRes
+		in   string
+		want string
+	}{
+		{name: "name and key",
+			grid: One16,
+			in:   "[kick]	{C1}	x...x...",
+			want: "[kick]\t{C1}\tx...x...........",
+		},
+		{name: "no name or key",
+			grid: One8,
+			in:   "x.x.",
+			want: "x.x.....",
+		},
+		{name: "multiple bars and patterns",
+			grid: One8,
+			in: `[kick]	{C1}	x...x...	x.x.x...;
+				[hihat]	{F#1}	xxxxxxxx`,
+			want: "[kick]\t{C1}\tx...x...\tx.x.x...;\n[hihat]\t{F#1}\txxxxxxxx",
+		},
+		{name: "modifiers",
+			grid: One16,
+			in:   "[snare]	{D1}	(g50%)x(r4-10,1:2)...x(flam:8,trig)...x(25%,g12)===x(drag)",
+			want: "[snare]\t{D1}\t(g50%)x(1:2,r4-10)...x(flam:8,trig)...x(25%,g12)===x(drag)...",
+		},
+		{name: "keys below C0",
+			grid: One8,
+			in:   "[low]\t{A#-1}\tx...;\n[lowest]\t{C#-2}\tx...",
+			want: "[low]\t{A#-1}\tx.......;\n[lowest]\t{C#-2}\tx.......",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := NewFromString(tt.grid, tt.in)
+			for _, p := range patterns {
+				p.ReAlign()
+			}
+			got := FormatString(patterns...)
+			if got != tt.want {
+				t.Fatalf("expected\n%q\ngot\n%q", tt.want, got)
+			}
+			parsed := NewFromString(tt.grid, got)
+			for _, p := range parsed {
+				p.ReAlign()
+			}
+			if !reflect.DeepEqual(parsed, patterns) {
+				t.Errorf("expected the formatted string to parse back to the same patterns")
+			}
+		})
+	}
+}
+
+func TestFormatString_keys(t *testing.T) {
+	for key := 1; key < 128; key++ {
+		p := &Pattern{PPQN: DefaultPPQN, Grid: One16, Key: key}
+		if got := NewFromString(One16, FormatString(p))[0].Key; got != key {
+			t.Errorf("expected key %d to be parsed back, got %d", key, got)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package drumbeat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+// PianoRollOptions are the settings used to draw patterns as a piano roll.
+type PianoRollOptions struct {
+	// Theme defaults to LightTheme.
+	Theme *Theme
+	// BeatWidth is the width of a quarter note in pixels, defaults to 80.
+	BeatWidth int
+	// RowHeight is the height of each pattern, defaults to 20.
+	RowHeight int
+	// VelocityHeight is the height of the velocity lane, defaults to 60.
+	VelocityHeight int
+}
+
+// pianoRollNote is a note drawn in the piano roll.
+type pianoRollNote struct {
+	row        int
+	start, end uint64
+	vel        uint8
+}
+
+// pianoRoll holds the resolved settings and the notes of a piano roll.
+type pianoRoll struct {
+	theme          Theme
+	labelWidth     int
+	rowHeight      int
+	velocityHeight int
+	// tickWidth is the width of a tick in pixels.
+	tickWidth float64
+	ppqn      uint64
+	stepTicks uint64
+	length    uint64
+	rows      []string
+	notes     []pianoRollNote
+}
+
+// newPianoRoll places the notes played by the patterns, nil patterns are
+// skipped. It returns nil if there are no patterns.
+func newPianoRoll(patterns []*Pattern, opts PianoRollOptions) *pianoRoll {
+	// row of each pattern
+	rowOf := map[int]int{}
+	var first *Pattern
+	var rows []string
+	for i, p := range patterns {
+		if p == nil {
+			continue
+		}
+		if first == nil {
+			first = p
+		}
+		rowOf[i] = len(rows)
+		rows = append(rows, p.Name)
+	}
+	if first == nil {
+		return nil
+	}
+	r := &pianoRoll{
+		theme:          LightTheme,
+		labelWidth:     140,
+		rowHeight:      opts.RowHeight,
+		velocityHeight: opts.VelocityHeight,
+		ppqn:           uint64(first.PPQN),
+		rows:           rows,
+	}
+	if opts.Theme != nil {
+		r.theme = *opts.Theme
+	}
+	if r.rowHeight <= 0 {
+		r.rowHeight = 20
+	}
+	if r.velocityHeight <= 0 {
+		r.velocityHeight = 60
+	}
+	if r.ppqn == 0 {
+		r.ppqn = uint64(DefaultPPQN)
+	}
+	beatWidth := opts.BeatWidth
+	if beatWidth <= 0 {
+		beatWidth = 80
+	}
+	r.tickWidth = float64(beatWidth) / float64(r.ppqn)
+	r.stepTicks = r.ppqn / first.Grid.StepsInBeat()
+	if r.stepTicks == 0 {
+		r.stepTicks = r.ppqn
+	}
+
+	events, length := Timeline(patterns...)
+	r.length = length
+	// index of the note playing on each key of each pattern
+	type voice struct{ pattern, key int }
+	playing := map[voice]int{}
+	for _, ev := range events {
+		v := voice{ev.Pattern, ev.Key}
+		if ev.On {
+			playing[v] = len(r.notes)
+			r.notes = append(r.notes, pianoRollNote{row: rowOf[ev.Pattern], start: ev.Ticks, end: ev.Ticks, vel: ev.Velocity})
+			continue
+		}
+		if idx, ok := playing[v]; ok {
+			r.notes[idx].end = ev.Ticks
+			delete(playing, v)
+		}
+		if ev.Ticks > r.length {
+			r.length = ev.Ticks
+		}
+	}
+	return r
+}
+
+// x returns the horizontal position of a tick.
+func (r *pianoRoll) x(tick uint64) float64 {
+	return float64(r.labelWidth) + float64(tick)*r.tickWidth
+}
+
+// width and height return the size of the piano roll.
+func (r *pianoRoll) width() int {
+	return int(math.Ceil(r.x(r.length)))
+}
+
+func (r *pianoRoll) height() int {
+	return r.laneTop() + r.velocityHeight
+}
+
+// laneTop returns the position of the velocity lane.
+func (r *pianoRoll) laneTop() int {
+	return len(r.rows) * r.rowHeight
+}
+
+// velocityBar returns the height of the velocity bar of a note.
+func (r *pianoRoll) velocityBar(vel uint8) int {
+	if vel > 127 {
+		vel = 127
+	}
+	return int(vel) * (r.velocityHeight - 4) / 127
+}
+
+// gridLines calls fn with the position of each step line, bar tells if the
+// step starts a bar.
+func (r *pianoRoll) gridLines(fn func(tick uint64, beat int, bar bool)) {
+	for tick := uint64(0); tick <= r.length; tick += r.stepTicks {
+		beat := int(tick / r.ppqn)
+		fn(tick, beat, tick%(4*r.ppqn) == 0)
+	}
+}
+
+// SaveAsPianoRollPNG draws the patterns as a piano roll: each note is placed
+// at its exact position with its real length so the microtiming, ratchets,
+// grace notes and gates are visible over the grid. The velocities are shown
+// in a lane below the patterns.
+func SaveAsPianoRollPNG(w io.Writer, patterns []*Pattern, opts PianoRollOptions) error {
+	r := newPianoRoll(patterns, opts)
+	if r == nil {
+		return nil
+	}
+	t := r.theme
+	width, laneTop := r.width(), r.laneTop()
+	img := image.NewRGBA(image.Rect(0, 0, width, r.height()))
+	fill := func(x0, y0, x1, y1 int, c color.Color) {
+		draw.Draw(img, image.Rect(x0, y0, x1, y1), image.NewUniform(c), image.ZP, draw.Over)
+	}
+	px := func(tick uint64) int {
+		return int(math.Floor(r.x(tick) + 0.5))
+	}
+
+	fill(0, 0, width, r.height(), t.LabelBackground)
+	// beat backgrounds
+	for beat := 0; uint64(beat)*r.ppqn < r.length; beat++ {
+		x0, x1 := px(uint64(beat)*r.ppqn), px(uint64(beat+1)*r.ppqn)
+		for row := range r.rows {
+			fill(x0, row*r.rowHeight, x1, (row+1)*r.rowHeight, t.Backgrounds[beat%2][row%2])
+		}
+		fill(x0, laneTop, x1, r.height(), t.Backgrounds[beat%2][0])
+	}
+	// step, beat and bar lines
+	r.gridLines(func(tick uint64, beat int, bar bool) {
+		x := px(tick)
+		for row := range r.rows {
+			col := t.GridStrokes[beat%2][row%2]
+			if bar {
+				col = t.HitStroke
+			}
+			fill(x, row*r.rowHeight, x+1, (row+1)*r.rowHeight, col)
+		}
+	})
+	for row := range r.rows {
+		fill(0, row*r.rowHeight, width, row*r.rowHeight+1, t.GridStrokes[0][0])
+	}
+	fill(0, laneTop, width, laneTop+1, t.HitStroke)
+
+	for _, n := range r.notes {
+		x0 := px(n.start)
+		x1 := px(n.end)
+		if x1 <= x0 {
+			x1 = x0 + 1
+		}
+		y0, y1 := n.row*r.rowHeight+2, (n.row+1)*r.rowHeight-2
+		hitFill := t.HitFill
+		hitFill.A = uint8(255 * VelocityOpacity(n.vel))
+		fill(x0, y0, x1, y1, hitFill)
+		// outline
+		fill(x0, y0, x1, y0+1, t.HitStroke)
+		fill(x0, y1-1, x1, y1, t.HitStroke)
+		fill(x0, y0, x0+1, y1, t.HitStroke)
+		fill(x1-1, y0, x1, y1, t.HitStroke)
+		// velocity
+		fill(x0, r.height()-r.velocityBar(n.vel), x0+3, r.height(), t.HitFill)
+	}
+
+	for row, name := range r.rows {
+		addLabel(img, 5, (row+1)*r.rowHeight-5, name, t.LabelText)
+	}
+	addLabel(img, 5, laneTop+16, "velocity", t.LabelText)
+	return png.Encode(w, img)
+}
+
+// SaveAsPianoRollSVG is the vector version of SaveAsPianoRollPNG, the notes
+// aren't rounded to the pixel.
+func SaveAsPianoRollSVG(w io.Writer, patterns []*Pattern, opts PianoRollOptions) error {
+	r := newPianoRoll(patterns, opts)
+	if r == nil {
+		return nil
+	}
+	t := r.theme
+	width, height, laneTop := r.width(), r.height(), r.laneTop()
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(buf, `<rect x="0" y="0" width="%d" height="%d" fill="%s"/>`+"\n", width, height, svgColor(t.LabelBackground))
+	// beat backgrounds
+	for beat := 0; uint64(beat)*r.ppqn < r.length; beat++ {
+		x := r.x(uint64(beat) * r.ppqn)
+		beatWidth := float64(r.ppqn) * r.tickWidth
+		for row := range r.rows {
+			fmt.Fprintf(buf, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s"/>`+"\n",
+				x, row*r.rowHeight, beatWidth, r.rowHeight, svgColor(t.Backgrounds[beat%2][row%2]))
+		}
+		fmt.Fprintf(buf, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s"/>`+"\n",
+			x, laneTop, beatWidth, r.velocityHeight, svgColor(t.Backgrounds[beat%2][0]))
+	}
+	// step, beat and bar lines
+	r.gridLines(func(tick uint64, beat int, bar bool) {
+		col := t.GridStrokes[beat%2][0]
+		if bar {
+			col = t.HitStroke
+		}
+		fmt.Fprintf(buf, `<line x1="%.2f" y1="0" x2="%.2f" y2="%d" stroke="%s"/>`+"\n",
+			r.x(tick), r.x(tick), laneTop, svgColor(col))
+	})
+	for row := range r.rows {
+		fmt.Fprintf(buf, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+			row*r.rowHeight, width, row*r.rowHeight, svgColor(t.GridStrokes[0][0]))
+	}
+	fmt.Fprintf(buf, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+		laneTop, width, laneTop, svgColor(t.HitStroke))
+
+	for _, n := range r.notes {
+		x := r.x(n.start)
+		noteWidth := math.Max(1, float64(n.end-n.start)*r.tickWidth)
+		fmt.Fprintf(buf, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s" fill-opacity="%.2f" stroke="%s"/>`+"\n",
+			x, n.row*r.rowHeight+2, noteWidth, r.rowHeight-4, svgColor(t.HitFill), VelocityOpacity(n.vel), svgColor(t.HitStroke))
+		bar := r.velocityBar(n.vel)
+		fmt.Fprintf(buf, `<rect x="%.2f" y="%d" width="3" height="%d" fill="%s"/>`+"\n",
+			x, height-bar, bar, svgColor(t.HitFill))
+	}
+
+	for row, name := range r.rows {
+		fmt.Fprintf(buf, `<text x="5" y="%d" font-family="monospace" font-size="13" fill="%s">`,
+			(row+1)*r.rowHeight-6, svgColor(t.LabelText))
+		xml.EscapeText(buf, []byte(name))
+		buf.WriteString("</text>\n")
+	}
+	fmt.Fprintf(buf, `<text x="5" y="%d" font-family="monospace" font-size="13" fill="%s">velocity</text>`+"\n",
+		laneTop+16, svgColor(t.LabelText))
+	buf.WriteString("</svg>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
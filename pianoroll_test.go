@@ -0,0 +1,110 @@
+package drumbeat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func pianoRollPatterns() []*Pattern {
+	patterns := NewFromString(One16, `
+		[kick]	x...x...;
+		[snare]	....x(r2)`)
+	patterns[0].Pulses[0].Velocity = 64
+	patterns[0].Pulses[4].Velocity = 127
+	// half a step late
+	patterns[0].Pulses[4].Nudge = 12
+	return patterns
+}
+
+func TestSaveAsPianoRollPNG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := SaveAsPianoRollPNG(buf, pianoRollPatterns(), PianoRollOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(460, 100) {
+		t.Fatalf("expected a 460x100 image, got %v", size)
+	}
+	tests := []struct {
+		name string
+		pt   image.Point
+		want color.NRGBA
+	}{
+		{"nudged note", image.Pt(235, 10), LightTheme.HitFill},
+		{"grid position of the nudged note", image.Pt(225, 10), LightTheme.Backgrounds[1][0]},
+		{"end of the nudged note", image.Pt(252, 10), LightTheme.Backgrounds[1][0]},
+		{"first ratchet", image.Pt(220, 30), LightTheme.HitStroke},
+		{"second ratchet", image.Pt(230, 30), LightTheme.HitStroke},
+		{"loud velocity", image.Pt(231, 50), LightTheme.HitFill},
+		{"soft velocity", image.Pt(141, 60), LightTheme.Backgrounds[0][0]},
+		{"soft velocity bar", image.Pt(141, 90), LightTheme.HitFill},
+		{"bar line", image.Pt(140, 30), LightTheme.HitStroke},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := color.NRGBAModel.Convert(img.At(tt.pt.X, tt.pt.Y)); got != tt.want {
+				t.Errorf("expected the pixel at %v to be %v, got %v", tt.pt, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSaveAsPianoRollSVG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := SaveAsPianoRollSVG(buf, pianoRollPatterns(), PianoRollOptions{BeatWidth: 96}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		`width="524" height="100"`,
+		// the nudged note, a pixel per tick
+		`<rect x="248.00" y="2" width="24.00" height="16"`,
+		// the ratchets, the last one lasts for the gate length
+		`<rect x="236.00" y="22" width="12.00" height="16"`,
+		`<rect x="248.00" y="22" width="24.00" height="16"`,
+		// velocity
+		`<rect x="140.00" y="72" width="3" height="28"`,
+		">kick</text>",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected the svg to contain %q\n%s", s, out)
+		}
+	}
+}
+
+func TestNewPianoRoll(t *testing.T) {
+	patterns := NewFromString(One16, "[kick]\t{C1}\tx(r2)...;\n[kick 2]\t{C1}\t..x.")
+	r := newPianoRoll([]*Pattern{nil, patterns[0], nil, patterns[1]}, PianoRollOptions{})
+	if len(r.rows) != 2 || r.rows[1] != "kick 2" {
+		t.Fatalf("expected the nil patterns to be skipped, got rows %q", r.rows)
+	}
+	want := []pianoRollNote{
+		{row: 0, start: 0, end: 12, vel: 90},
+		{row: 0, start: 12, end: 36, vel: 90},
+		{row: 1, start: 48, end: 72, vel: 90},
+	}
+	if !reflect.DeepEqual(r.notes, want) {
+		t.Errorf("expected the notes\n%+v\ngot\n%+v", want, r.notes)
+	}
+	if r := newPianoRoll([]*Pattern{nil}, PianoRollOptions{}); r != nil {
+		t.Errorf("expected no piano roll without patterns")
+	}
+}
+
+func TestNewPianoRoll_offGrid(t *testing.T) {
+	p := NewFromString(One16, "x.x.")[0]
+	p.Pulses[0].Ticks = 10
+	p.Pulses[2].Nudge = -4
+	r := newPianoRoll([]*Pattern{p}, PianoRollOptions{})
+	if len(r.notes) != 2 || r.notes[0].start != 10 || r.notes[1].start != 44 {
+		t.Errorf("expected the notes to start at ticks 10 and 44, got %+v", r.notes)
+	}
+}
@@ -10,6 +10,125 @@ type Pulse struct {
 	Ticks    uint64
 	Duration uint16
 	Velocity uint8
+	// Nudge is the amount of ticks the pulse is played ahead (negative value)
+	// or behind (positive value) its position on the grid.
+	Nudge int16
+	// Probability is the chance (1 to 99%) of the pulse to be triggered when
+	// rendering loops. 0 and values of 100 or more mean always.
+	Probability uint8
+	// Condition restricts the loops in which the pulse is triggered.
+	Condition Condition
+	// Ratchets is the number of hits evenly spread within the step, 0 and 1
+	// mean a single hit.
+	Ratchets uint8
+	// RatchetRamp is the velocity change between the first and the last
+	// ratchet hits, use a positive value for a crescendo.
+	RatchetRamp int8
+	// Ornament adds grace notes before the pulse.
+	Ornament Ornament
+	// GraceOffset is the distance in ticks between the grace notes and the
+	// pulse, defaults to a quarter of the step.
+	GraceOffset uint16
+	// Gate overrides the gate of the pattern for this pulse.
+	Gate Gate
+}
+
+// Ornament is a grace note embellishment played before a pulse.
+type Ornament string
+
+const (
+	NoOrnament Ornament = ""
+	// Flam is a single soft grace note.
+	Flam Ornament = "flam"
+	// Drag is a double soft grace note.
+	Drag Ornament = "drag"
+)
+
+// Hit is a single drum hit, pulses with ratchets or ornaments are made of
+// multiple hits.
+type Hit struct {
+	// Offset is the position of the hit in ticks relative to the start of the
+	// pulse, grace notes have negative offsets.
+	Offset int64
+	// Duration of the hit in ticks until the next hit of the pulse, 0 for the
+	// last hit.
+	Duration uint64
+	Velocity uint8
+}
+
+// Hits expands the ratchets and ornaments of the pulse into individual hits.
+// The step size is the size in ticks of the step the pulse is in.
+func (p *Pulse) Hits(stepSize uint64) []Hit {
+	if p == nil {
+		return nil
+	}
+	hits := []Hit{}
+	grace := int64(p.GraceOffset)
+	if grace == 0 {
+		grace = int64(stepSize / 4)
+	}
+	graceVel := scaleVelocity(int(p.Velocity) * 3 / 5)
+	switch p.Ornament {
+	case Flam:
+		hits = append(hits, Hit{Offset: -grace, Duration: uint64(grace), Velocity: graceVel})
+	case Drag:
+		hits = append(hits,
+			Hit{Offset: -grace, Duration: uint64(grace / 2), Velocity: graceVel},
+			Hit{Offset: -grace / 2, Duration: uint64(grace - grace/2), Velocity: graceVel})
+	}
+
+	n := int(p.Ratchets)
+	if n < 1 {
+		n = 1
+	}
+	ratchetSize := stepSize / uint64(n)
+	for i := 0; i < n; i++ {
+		vel := int(p.Velocity)
+		if n > 1 {
+			vel += int(p.RatchetRamp) * i / (n - 1)
+		}
+		h := Hit{Offset: int64(uint64(i) * ratchetSize), Velocity: scaleVelocity(vel)}
+		if i < n-1 {
+			h.Duration = ratchetSize
+		}
+		hits = append(hits, h)
+	}
+	return hits
+}
+
+// hitsAt returns the hits of the pulse played from the start tick, the grace
+// notes which would be played before the first tick are dropped.
+func (p *Pulse) hitsAt(start, stepSize uint64) []Hit {
+	hits := p.Hits(stepSize)
+	for len(hits) > 1 && hits[0].Offset < 0 && uint64(-hits[0].Offset) > start {
+		hits = hits[1:]
+	}
+	return hits
+}
+
+// scaleVelocity clamps a velocity to the MIDI range.
+func scaleVelocity(vel int) uint8 {
+	if vel > 127 {
+		return 127
+	}
+	if vel < 1 {
+		return 1
+	}
+	return uint8(vel)
+}
+
+// Start returns the tick at which the pulse should be played, taking the
+// nudge into account.
+func (p *Pulse) Start() uint64 {
+	return nudge(p.Ticks, int64(p.Nudge))
+}
+
+// nudge moves ticks by n without going below 0.
+func nudge(ticks uint64, n int64) uint64 {
+	if n < 0 && uint64(-n) > ticks {
+		return 0
+	}
+	return uint64(int64(ticks) + n)
 }
 
 // String implements the stringer interface
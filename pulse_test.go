@@ -0,0 +1,68 @@
+package drumbeat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPulse_Hits(t *testing.T) {
+	tests := []struct {
+		name  string
+		pulse *Pulse
+		want  []Hit
+	}{
+		{name: "single hit",
+			pulse: &Pulse{Velocity: 90},
+			want:  []Hit{{Offset: 0, Velocity: 90}}},
+		{name: "ratchet",
+			pulse: &Pulse{Velocity: 90, Ratchets: 3},
+			want:  []Hit{{Offset: 0, Duration: 8, Velocity: 90}, {Offset: 8, Duration: 8, Velocity: 90}, {Offset: 16, Velocity: 90}}},
+		{name: "ratchet with ramp",
+			pulse: &Pulse{Velocity: 60, Ratchets: 4, RatchetRamp: 60},
+			want: []Hit{
+				{Offset: 0, Duration: 6, Velocity: 60}, {Offset: 6, Duration: 6, Velocity: 80},
+				{Offset: 12, Duration: 6, Velocity: 100}, {Offset: 18, Velocity: 120}}},
+		{name: "ratchet ramp is clamped",
+			pulse: &Pulse{Velocity: 100, Ratchets: 2, RatchetRamp: 100},
+			want:  []Hit{{Offset: 0, Duration: 12, Velocity: 100}, {Offset: 12, Velocity: 127}}},
+		{name: "flam",
+			pulse: &Pulse{Velocity: 100, Ornament: Flam},
+			want:  []Hit{{Offset: -6, Duration: 6, Velocity: 60}, {Offset: 0, Velocity: 100}}},
+		{name: "drag with custom offset",
+			pulse: &Pulse{Velocity: 100, Ornament: Drag, GraceOffset: 8},
+			want:  []Hit{{Offset: -8, Duration: 4, Velocity: 60}, {Offset: -4, Duration: 4, Velocity: 60}, {Offset: 0, Velocity: 100}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pulse.Hits(24); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pulse.Hits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromString_ornaments(t *testing.T) {
+	p := NewFromString(One16, "x(r3)x(r4-20).x(flam)x(drag:8)x(r0)x(flamboyant)")[0]
+	if got := p.Pulses.String(); got != "xx.xxxx" {
+		t.Fatalf("expected the modifiers not to be steps, got %s", got)
+	}
+	want := []Pulse{
+		{Ratchets: 3},
+		{Ratchets: 4, RatchetRamp: -20},
+		{},
+		{Ornament: Flam},
+		{Ornament: Drag, GraceOffset: 8},
+		{},
+		{},
+	}
+	for i, w := range want {
+		pulse := p.Pulses[i]
+		if pulse == nil {
+			continue
+		}
+		if pulse.Ratchets != w.Ratchets || pulse.RatchetRamp != w.RatchetRamp ||
+			pulse.Ornament != w.Ornament || pulse.GraceOffset != w.GraceOffset {
+			t.Errorf("[%d] expected %+v, got %+v", i, w, *pulse)
+		}
+	}
+}
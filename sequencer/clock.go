@@ -0,0 +1,20 @@
+package sequencer
+
+import "time"
+
+// Clock provides the time to the sequencer. It can be replaced by a fake
+// clock in tests or to sync the playback to another time source.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel receiving the time once the duration elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the clock of the system.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,321 @@
+package sequencer
+
+import (
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// MIDI real time and song position messages.
+const (
+	ClockTick     byte = 0xF8
+	ClockStart    byte = 0xFA
+	ClockContinue byte = 0xFB
+	ClockStop     byte = 0xFC
+	SongPosition  byte = 0xF2
+)
+
+// ClocksPerBeat is the resolution of the MIDI clock.
+const ClocksPerBeat = 24
+
+// clocksPerSongPosition is the number of clocks in a song position pointer
+// unit (a 16th note).
+const clocksPerSongPosition = 6
+
+// ClockEvent is a MIDI clock message at a position in ticks.
+type ClockEvent struct {
+	Ticks   uint64
+	Message []byte
+}
+
+// ClockEvents returns the MIDI clock messages playing the patterns once: a
+// start, 24 clocks per quarter note and a stop at the end of the patterns.
+func ClockEvents(patterns ...*drumbeat.Pattern) []ClockEvent {
+	_, length := drumbeat.Timeline(patterns...)
+	ppqn := drumbeat.DefaultPPQN
+	for _, p := range patterns {
+		if p != nil && p.PPQN > 0 {
+			ppqn = p.PPQN
+			break
+		}
+	}
+	period := float64(ppqn) / ClocksPerBeat
+	evs := []ClockEvent{{Message: []byte{ClockStart}}}
+	for i := 0; float64(i)*period < float64(length); i++ {
+		evs = append(evs, ClockEvent{Ticks: uint64(float64(i) * period), Message: []byte{ClockTick}})
+	}
+	return append(evs, ClockEvent{Ticks: length, Message: []byte{ClockStop}})
+}
+
+// SongPositionMessage returns the song position pointer message of the
+// passed position in ticks. The position is rounded down to a 16th note.
+func SongPositionMessage(ticks uint64, ppqn uint16) []byte {
+	pos := ticks * ClocksPerBeat / uint64(ppqn) / clocksPerSongPosition
+	if pos > 0x3FFF {
+		pos = 0x3FFF
+	}
+	return []byte{SongPosition, byte(pos & 0x7F), byte(pos >> 7)}
+}
+
+// SetClockOutput sets the writer the MIDI clock is sent to while playing, nil
+// disables the clock output. Start, Stop, Continue and the song position are
+// sent when the transport changes.
+func (s *Sequencer) SetClockOutput(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clockOut = w
+	if s.state == Playing {
+		s.nextClock = s.clockAfter(s.tickAt(s.clock.Now()))
+	}
+	s.notify()
+}
+
+// writeClock sends a message to the clock output.
+func (s *Sequencer) writeClock(msg ...byte) {
+	if s.clockOut == nil {
+		return
+	}
+	if _, err := s.clockOut.Write(msg); err != nil {
+		s.err = err
+	}
+}
+
+// startClock sends the messages starting the clock from the passed position.
+func (s *Sequencer) startClock(pos float64) {
+	if s.clockOut == nil {
+		return
+	}
+	s.nextClock = s.clockAfter(pos)
+	if pos <= 0 {
+		s.writeClock(ClockStart)
+		return
+	}
+	s.writeClock(SongPositionMessage(uint64(pos), s.ppqn)...)
+	s.writeClock(ClockContinue)
+}
+
+// sendClocks sends the clocks due at the passed position. When not looping,
+// the clock stops at the end of the patterns.
+func (s *Sequencer) sendClocks(cur float64) {
+	if s.clockOut == nil {
+		return
+	}
+	if !s.loop && s.length > 0 && cur >= float64(s.length) {
+		cur = math.Nextafter(float64(s.length), 0)
+	}
+	for ; s.nextClock <= cur; s.nextClock += s.clockPeriod() {
+		s.writeClock(ClockTick)
+	}
+}
+
+// clockAfter returns the position of the first clock at or after pos.
+func (s *Sequencer) clockAfter(pos float64) float64 {
+	period := s.clockPeriod()
+	return math.Ceil(pos/period) * period
+}
+
+// clockPeriod returns the number of ticks between 2 clocks.
+func (s *Sequencer) clockPeriod() float64 {
+	return float64(s.ppqn) / ClocksPerBeat
+}
+
+// align moves the playhead to the position of the nth clock after base
+// without turning off the notes being played, it's used to follow an
+// external clock.
+func (s *Sequencer) align(base float64, clocks uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != Playing {
+		return
+	}
+	now := s.clock.Now()
+	pos := base + float64(clocks)*s.clockPeriod()
+	if s.loop && s.length > 0 {
+		length := float64(s.length)
+		pos = math.Mod(pos, length)
+		// don't move across the loop boundary
+		switch cur := s.tickAt(now); {
+		case pos-cur > length/2:
+			pos -= length
+		case cur-pos > length/2:
+			pos += length
+		}
+	}
+	s.anchor(now, pos)
+	s.notify()
+}
+
+// DefaultFollowWindow is the number of clock intervals averaged by a
+// follower to estimate the tempo, a beat.
+const DefaultFollowWindow = ClocksPerBeat
+
+// Follower syncs a sequencer to an incoming MIDI clock. The tempo is
+// estimated from the interval between the clocks, the outliers are ignored
+// and the others averaged to smooth the jitter. The sequencer's position is
+// realigned on each clock.
+type Follower struct {
+	// Window is the number of clock intervals used to estimate the tempo.
+	Window int
+
+	seq   *Sequencer
+	clock Clock
+
+	intervals []time.Duration
+	last      time.Time
+	// mu guards the tempo, read while following.
+	mu    sync.Mutex
+	tempo float64
+
+	// running is true between a start/continue and a stop.
+	running bool
+	// started is true once the first clock after a start was received.
+	started bool
+	// base is the position in ticks the clocks are counted from.
+	base   float64
+	clocks uint64
+}
+
+// NewFollower returns a follower driving the sequencer. The sequencer's
+// clock is used to time the incoming messages.
+func NewFollower(seq *Sequencer) *Follower {
+	return &Follower{Window: DefaultFollowWindow, seq: seq, clock: seq.clock}
+}
+
+// Tempo returns the estimated tempo in beats per minute, 0 until enough
+// clocks were received.
+func (f *Follower) Tempo() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tempo
+}
+
+// Follow reads MIDI messages until the end of the reader and drives the
+// sequencer using the clock, transport and song position messages, the
+// other messages are ignored.
+func (f *Follower) Follow(r io.Reader) error {
+	buf := make([]byte, 1)
+	// data bytes of the song position pointer being read
+	var spp []byte
+	var inSPP bool
+	for {
+		// ReadFull skips the empty reads and returns the last byte before
+		// reporting io.EOF.
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		b := buf[0]
+		switch {
+		case b >= 0xF8:
+			// real time messages can be interleaved with other messages
+			f.handle(b)
+		case b == SongPosition:
+			inSPP = true
+			spp = spp[:0]
+		case b&0x80 != 0:
+			inSPP = false
+		case inSPP:
+			spp = append(spp, b)
+			if len(spp) == 2 {
+				inSPP = false
+				f.songPosition(int(spp[0]) | int(spp[1])<<7)
+			}
+		}
+	}
+}
+
+func (f *Follower) handle(msg byte) {
+	switch msg {
+	case ClockTick:
+		f.tick()
+	case ClockStart:
+		f.seq.Stop()
+		f.base = 0
+		f.running = true
+		f.started = false
+	case ClockContinue:
+		f.base = float64(f.seq.Position())
+		f.running = true
+		f.started = false
+	case ClockStop:
+		f.running = false
+		f.seq.Pause()
+	}
+}
+
+// songPosition moves the sequencer to the position, in 16th notes.
+func (f *Follower) songPosition(pos int) {
+	f.seq.mu.Lock()
+	ppqn := f.seq.ppqn
+	f.seq.mu.Unlock()
+	f.base = float64(uint64(pos) * uint64(ppqn) / 4)
+	if !f.running {
+		f.seq.Seek(uint64(f.base))
+	}
+}
+
+func (f *Follower) tick() {
+	now := f.clock.Now()
+	if !f.last.IsZero() {
+		f.intervals = append(f.intervals, now.Sub(f.last))
+		if f.Window < 1 {
+			f.Window = DefaultFollowWindow
+		}
+		if len(f.intervals) > f.Window {
+			f.intervals = f.intervals[len(f.intervals)-f.Window:]
+		}
+		f.estimate()
+	}
+	f.last = now
+
+	if !f.running {
+		return
+	}
+	if !f.started {
+		// the playback starts on the first clock following a start
+		f.started = true
+		f.clocks = 0
+		f.seq.Seek(uint64(f.base))
+		f.seq.Start()
+		return
+	}
+	f.clocks++
+	f.seq.align(f.base, f.clocks)
+}
+
+// estimate updates the tempo using the average of the intervals close to
+// their median.
+func (f *Follower) estimate() {
+	sorted := make([]time.Duration, len(f.intervals))
+	copy(sorted, f.intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := sorted[len(sorted)/2]
+	var sum time.Duration
+	var n int
+	for _, d := range sorted {
+		if d >= median/2 && d <= median*2 {
+			sum += d
+			n++
+		}
+	}
+	if n == 0 || sum <= 0 {
+		return
+	}
+	avg := float64(sum) / float64(n)
+	tempo := float64(time.Minute) / (avg * ClocksPerBeat)
+	// ignore the tiny variations to avoid rescheduling the playback
+	f.mu.Lock()
+	if math.Abs(tempo-f.tempo) < 0.01 {
+		f.mu.Unlock()
+		return
+	}
+	f.tempo = tempo
+	f.mu.Unlock()
+	f.seq.SetTempo(tempo)
+}
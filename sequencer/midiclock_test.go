@@ -0,0 +1,197 @@
+package sequencer
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+)
+
+func TestClockEvents(t *testing.T) {
+	evs := ClockEvents(drumbeat.NewFromString(drumbeat.One16, "[kick]\t{C1}\tx...")...)
+	// start, 24 clocks per beat during a bar and stop
+	if len(evs) != 98 {
+		t.Fatalf("expected 98 events, got %d", len(evs))
+	}
+	if !reflect.DeepEqual(evs[0], ClockEvent{Message: []byte{ClockStart}}) {
+		t.Errorf("expected a start, got %+v", evs[0])
+	}
+	if !reflect.DeepEqual(evs[2], ClockEvent{Ticks: 4, Message: []byte{ClockTick}}) {
+		t.Errorf("expected a clock every 4 ticks, got %+v", evs[2])
+	}
+	if !reflect.DeepEqual(evs[97], ClockEvent{Ticks: 384, Message: []byte{ClockStop}}) {
+		t.Errorf("expected a stop at the end of the bar, got %+v", evs[97])
+	}
+}
+
+func TestSongPositionMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		ticks uint64
+		want  []byte
+	}{
+		{name: "start", ticks: 0, want: []byte{SongPosition, 0, 0}},
+		{name: "16th", ticks: 24, want: []byte{SongPosition, 1, 0}},
+		{name: "rounded down", ticks: 47, want: []byte{SongPosition, 1, 0}},
+		{name: "8 bars", ticks: 8 * 384, want: []byte{SongPosition, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SongPositionMessage(tt.ticks, 96); !bytes.Equal(got, tt.want) {
+				t.Errorf("expected % X, got % X", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSequencer_SetClockOutput(t *testing.T) {
+	clk := newFakeClock()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	out := &bytes.Buffer{}
+	seq.SetClockOutput(out)
+	seq.SetPatterns(drumbeat.NewFromString(drumbeat.One16, "[kick]\t{C1}\tx...")...)
+	seq.Start()
+	waitState(t, clk, seq, Stopped)
+
+	want := append([]byte{ClockStart}, bytes.Repeat([]byte{ClockTick}, 96)...)
+	want = append(want, ClockStop)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("expected % X\ngot % X", want, out.Bytes())
+	}
+
+	out.Reset()
+	seq.Seek(96)
+	seq.Start()
+	seq.Pause()
+	// the clock at the position might be sent before pausing
+	want = []byte{SongPosition, 4, 0, SongPosition, 4, 0, ClockContinue}
+	if got := out.Bytes(); !bytes.HasPrefix(got, want) || got[len(got)-1] != ClockStop {
+		t.Errorf("expected % X ... FC\ngot % X", want, got)
+	}
+}
+
+// timedReader returns a byte at a time, advancing the clock before each one.
+type timedReader struct {
+	clk    *fakeClock
+	data   []byte
+	delays []time.Duration
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	r.clk.Advance(r.delays[0])
+	p[0] = r.data[0]
+	r.data, r.delays = r.data[1:], r.delays[1:]
+	return 1, nil
+}
+
+func (r *timedReader) add(b byte, delay time.Duration) {
+	r.data = append(r.data, b)
+	r.delays = append(r.delays, delay)
+}
+
+func TestFollower(t *testing.T) {
+	clk := newFakeClock()
+	seq := New(newChanSink(), clk)
+	seq.SetLoop(true)
+	seq.SetPatterns(drumbeat.NewFromString(drumbeat.One16, "[kick]\t{C1}\tx...")...)
+	f := NewFollower(seq)
+
+	// 2 beats at 100 BPM with some jitter
+	interval := time.Minute / (100 * ClocksPerBeat)
+	jitter := []time.Duration{0, time.Millisecond, -time.Millisecond, 500 * time.Microsecond, -500 * time.Microsecond}
+	r := &timedReader{clk: clk}
+	r.add(ClockStart, 0)
+	for i := 0; i < 2*ClocksPerBeat; i++ {
+		r.add(ClockTick, interval+jitter[i%len(jitter)])
+	}
+	errc := make(chan error)
+	go func() { errc <- f.Follow(r) }()
+	// the tempo can be read while following
+	var err error
+	for following := true; following; {
+		select {
+		case err = <-errc:
+			following = false
+		default:
+			f.Tempo()
+			runtime.Gosched()
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(f.Tempo()-100) > 0.5 {
+		t.Errorf("expected a tempo around 100 BPM, got %f", f.Tempo())
+	}
+	if seq.Tempo() != f.Tempo() {
+		t.Errorf("expected the sequencer to play at %f BPM, got %f", f.Tempo(), seq.Tempo())
+	}
+	if seq.State() != Playing {
+		t.Errorf("expected the sequencer to be playing, got %s", seq.State())
+	}
+	// the playback started on the first clock, 47 clocks ago
+	if pos := seq.Position(); pos != 47*4 {
+		t.Errorf("expected the sequencer to be at tick %d, got %d", 47*4, pos)
+	}
+
+	// other messages and real time messages within a song position pointer
+	// are handled.
+	if err := f.Follow(bytes.NewReader([]byte{ClockStop, 0x90, 36, 100, SongPosition, 4, ClockTick, 0})); err != nil {
+		t.Fatal(err)
+	}
+	if seq.State() != Paused {
+		t.Errorf("expected the sequencer to be paused, got %s", seq.State())
+	}
+	if pos := seq.Position(); pos != 96 {
+		t.Errorf("expected the sequencer to be at tick 96, got %d", pos)
+	}
+
+	if err := f.Follow(bytes.NewReader([]byte{ClockContinue, ClockTick})); err != nil {
+		t.Fatal(err)
+	}
+	if seq.State() != Playing || seq.Position() != 96 {
+		t.Errorf("expected the sequencer to continue from tick 96, got %s at %d", seq.State(), seq.Position())
+	}
+
+	// empty reads are skipped and the bytes read along with an error are
+	// handled.
+	if err := f.Follow(&stutterReader{data: []byte{ClockTick, ClockTick, ClockStop}}); err != nil {
+		t.Fatal(err)
+	}
+	if seq.State() != Paused || seq.Position() != 104 {
+		t.Errorf("expected the sequencer to be paused at tick 104, got %s at %d", seq.State(), seq.Position())
+	}
+	seq.Stop()
+}
+
+// stutterReader returns no data every other read and its last byte along with
+// io.EOF.
+type stutterReader struct {
+	data  []byte
+	empty bool
+}
+
+func (r *stutterReader) Read(p []byte) (int, error) {
+	r.empty = !r.empty
+	if r.empty {
+		return 0, nil
+	}
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:1], r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
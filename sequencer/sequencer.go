@@ -0,0 +1,457 @@
+// Package sequencer plays drum patterns in real time.
+package sequencer
+
+import (
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+)
+
+// DefaultBPM is the tempo of a new sequencer.
+const DefaultBPM = 120.0
+
+// idleWait is how long the sequencer sleeps when it has nothing to play, it's
+// woken up as soon as its patterns or settings change.
+const idleWait = time.Hour
+
+// Event is a note event played by the sequencer.
+type Event struct {
+	drumbeat.NoteEvent
+	// Name of the pattern triggering the note.
+	Name string
+	// Time is when the event was due according to the sequencer's clock.
+	Time time.Time
+}
+
+// EventSink receives the events played by the sequencer, it could send them
+// to a MIDI port, an OSC server or a sample player. Send is called from the
+// playback goroutine and must not call the sequencer back.
+type EventSink interface {
+	Send(ev Event) error
+}
+
+// State is the playback state of the sequencer.
+type State int
+
+const (
+	Stopped State = iota
+	Playing
+	Paused
+)
+
+func (s State) String() string {
+	switch s {
+	case Playing:
+		return "playing"
+	case Paused:
+		return "paused"
+	}
+	return "stopped"
+}
+
+// Sequencer plays patterns in its own goroutine and sends the note events to
+// a sink when they are due. Its methods are safe to call concurrently, the
+// patterns can be replaced while playing.
+type Sequencer struct {
+	mu    sync.Mutex
+	clock Clock
+	sink  EventSink
+	err   error
+
+	events []drumbeat.NoteEvent
+	names  []string
+	length uint64
+	ppqn   uint16
+	bpm    float64
+	loop   bool
+	state  State
+
+	// pos is the position in ticks when not playing.
+	pos float64
+	// anchorTime and anchorTick map the clock time to a position while
+	// playing.
+	anchorTime time.Time
+	anchorTick float64
+	// idx is the index of the next event to play and played the position
+	// up to which the events were played.
+	idx    int
+	played float64
+	// playing are the keys of the notes that were turned on, mapped to the
+	// index of their pattern.
+	playing map[int]int
+
+	// clockOut receives the MIDI clock, nextClock is the position of the
+	// next clock to send.
+	clockOut  io.Writer
+	nextClock float64
+
+	stop chan struct{}
+	done chan struct{}
+	wake chan struct{}
+}
+
+// New returns a stopped sequencer sending its events to the sink. The system
+// clock is used if clock is nil.
+func New(sink EventSink, clock Clock) *Sequencer {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &Sequencer{
+		clock:   clock,
+		sink:    sink,
+		ppqn:    drumbeat.DefaultPPQN,
+		bpm:     DefaultBPM,
+		playing: map[int]int{},
+		wake:    make(chan struct{}),
+	}
+}
+
+// SetPatterns replaces the patterns being played, the playback continues at
+// the same position. The patterns are converted when set so they can be
+// edited and set again while playing.
+func (s *Sequencer) SetPatterns(patterns ...*drumbeat.Pattern) {
+	events, length := drumbeat.Timeline(patterns...)
+	names := make([]string, len(patterns))
+	ppqn := drumbeat.DefaultPPQN
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i] == nil {
+			continue
+		}
+		names[i] = patterns[i].Name
+		if patterns[i].PPQN > 0 {
+			ppqn = patterns[i].PPQN
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	pos := s.position(now)
+	if s.state == Playing {
+		s.anchor(now, pos)
+	}
+	s.allNotesOff(now)
+	s.events, s.names, s.length, s.ppqn = events, names, length, ppqn
+	if s.state == Playing {
+		s.seekIndex(s.played, true)
+	} else {
+		s.seekIndex(pos, false)
+	}
+	s.notify()
+}
+
+// SetTempo changes the tempo in beats per minute.
+func (s *Sequencer) SetTempo(bpm float64) error {
+	if bpm <= 0 {
+		return drumbeat.ErrInvalidTempo
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == Playing {
+		now := s.clock.Now()
+		s.anchor(now, s.tickAt(now))
+	}
+	s.bpm = bpm
+	s.notify()
+	return nil
+}
+
+// Tempo returns the tempo in beats per minute.
+func (s *Sequencer) Tempo() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bpm
+}
+
+// SetLoop sets whether the patterns are looped or played once.
+func (s *Sequencer) SetLoop(loop bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loop = loop
+	s.notify()
+}
+
+// Start starts or resumes the playback.
+func (s *Sequencer) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a halted playback might still be running, it has to exit before a new
+	// one starts
+	for s.state != Playing && s.done != nil {
+		done := s.done
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+		if s.done == done {
+			s.done = nil
+		}
+	}
+	if s.state == Playing {
+		return
+	}
+	s.state = Playing
+	s.anchor(s.clock.Now(), s.pos)
+	s.played = s.pos - 0.5
+	s.startClock(s.pos)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Pause stops the playback at the current position, the notes being played
+// are turned off.
+func (s *Sequencer) Pause() {
+	s.halt(Paused)
+}
+
+// Stop stops the playback and goes back to the start of the patterns, the
+// notes being played are turned off.
+func (s *Sequencer) Stop() {
+	s.halt(Stopped)
+}
+
+// Seek moves the playback to the passed position in ticks.
+func (s *Sequencer) Seek(ticks uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.allNotesOff(now)
+	pos := float64(ticks)
+	if s.state == Playing {
+		s.anchor(now, pos)
+		s.played = pos - 0.5
+		s.writeClock(ClockStop)
+		s.startClock(pos)
+	} else {
+		s.pos = pos
+		s.writeClock(SongPositionMessage(ticks, s.ppqn)...)
+	}
+	s.seekIndex(pos, false)
+	s.notify()
+}
+
+// Position returns the current position in ticks.
+func (s *Sequencer) Position() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint64(s.position(s.clock.Now()))
+}
+
+// State returns the playback state.
+func (s *Sequencer) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Err returns the last error returned by the sink.
+func (s *Sequencer) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// halt stops the playback goroutine and waits for it to return.
+func (s *Sequencer) halt(state State) {
+	s.mu.Lock()
+	now := s.clock.Now()
+	if s.state == Playing {
+		s.pos = s.position(now)
+		close(s.stop)
+		done := s.done
+		s.state = state
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+		s.writeClock(ClockStop)
+	}
+	s.state = state
+	if state == Stopped {
+		s.pos = 0
+	}
+	s.allNotesOff(now)
+	s.seekIndex(s.pos, false)
+	s.mu.Unlock()
+}
+
+// run plays the events until the sequencer is stopped or reaches the end of
+// the patterns.
+func (s *Sequencer) run(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		s.mu.Lock()
+		if s.state != Playing {
+			s.mu.Unlock()
+			return
+		}
+		now := s.clock.Now()
+		wait, playing := s.play(now)
+		if !playing {
+			s.state = Stopped
+			s.pos = 0
+			s.writeClock(ClockStop)
+			s.allNotesOff(now)
+			s.seekIndex(0, false)
+			s.mu.Unlock()
+			return
+		}
+		wake := s.wake
+		s.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-wake:
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// play sends the events due at the passed time and returns how long to wait
+// until the next one. It returns false once the end of the patterns is
+// reached when not looping.
+func (s *Sequencer) play(now time.Time) (time.Duration, bool) {
+	if s.length == 0 && s.clockOut == nil {
+		return idleWait, true
+	}
+	cur := s.tickAt(now)
+	for {
+		s.sendClocks(cur)
+		for s.idx < len(s.events) && float64(s.events[s.idx].Ticks) <= cur {
+			s.send(s.events[s.idx])
+			s.idx++
+		}
+		if s.length == 0 || cur < float64(s.length) {
+			break
+		}
+		if !s.loop {
+			if s.idx < len(s.events) {
+				// notes ending after the patterns
+				break
+			}
+			return 0, false
+		}
+		// the notes still playing at the end of the loop are turned off
+		for ; s.idx < len(s.events); s.idx++ {
+			if !s.events[s.idx].On {
+				s.send(s.events[s.idx])
+			}
+		}
+		s.anchorTick -= float64(s.length)
+		s.nextClock -= float64(s.length)
+		cur -= float64(s.length)
+		s.idx = 0
+	}
+	s.played = cur
+
+	next := float64(s.length)
+	if s.idx < len(s.events) {
+		next = float64(s.events[s.idx].Ticks)
+		if s.loop && next > float64(s.length) {
+			next = float64(s.length)
+		}
+	}
+	if s.clockOut != nil && (s.length == 0 || s.nextClock < next) {
+		next = s.nextClock
+	}
+	return time.Duration(math.Ceil((next - cur) * s.tickDuration())), true
+}
+
+// send sends an event to the sink and keeps track of the notes playing, note
+// offs of notes that aren't playing are dropped.
+func (s *Sequencer) send(ev drumbeat.NoteEvent) {
+	if ev.On {
+		s.playing[ev.Key] = ev.Pattern
+	} else {
+		// the note was already turned off when seeking or changing patterns
+		if _, ok := s.playing[ev.Key]; !ok {
+			return
+		}
+		delete(s.playing, ev.Key)
+	}
+	var name string
+	if ev.Pattern >= 0 && ev.Pattern < len(s.names) {
+		name = s.names[ev.Pattern]
+	}
+	due := s.anchorTime.Add(time.Duration((float64(ev.Ticks) - s.anchorTick) * s.tickDuration()))
+	if s.sink == nil {
+		return
+	}
+	if err := s.sink.Send(Event{NoteEvent: ev, Name: name, Time: due}); err != nil {
+		s.err = err
+	}
+}
+
+// allNotesOff turns off the notes being played.
+func (s *Sequencer) allNotesOff(now time.Time) {
+	keys := make([]int, 0, len(s.playing))
+	for k := range s.playing {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for _, k := range keys {
+		ev := drumbeat.NoteEvent{Ticks: uint64(s.position(now)), Key: k, Pattern: s.playing[k]}
+		delete(s.playing, k)
+		if s.sink == nil {
+			continue
+		}
+		var name string
+		if ev.Pattern < len(s.names) {
+			name = s.names[ev.Pattern]
+		}
+		if err := s.sink.Send(Event{NoteEvent: ev, Name: name, Time: now}); err != nil {
+			s.err = err
+		}
+	}
+}
+
+// seekIndex moves the index of the next event to the passed position. The
+// events at the position are skipped if they were already played.
+func (s *Sequencer) seekIndex(pos float64, played bool) {
+	s.idx = sort.Search(len(s.events), func(i int) bool {
+		t := float64(s.events[i].Ticks)
+		if played {
+			return t > pos
+		}
+		return t >= pos
+	})
+}
+
+// anchor maps the passed time to the passed position.
+func (s *Sequencer) anchor(now time.Time, pos float64) {
+	s.anchorTime = now
+	s.anchorTick = pos
+}
+
+// tickAt returns the playback position at the passed time.
+func (s *Sequencer) tickAt(now time.Time) float64 {
+	return s.anchorTick + float64(now.Sub(s.anchorTime))/s.tickDuration()
+}
+
+// position returns the position in ticks, within the loop when looping.
+func (s *Sequencer) position(now time.Time) float64 {
+	pos := s.pos
+	if s.state == Playing {
+		pos = s.tickAt(now)
+	}
+	if s.loop && s.length > 0 {
+		pos = math.Mod(pos, float64(s.length))
+	}
+	return pos
+}
+
+// tickDuration returns the duration of a tick in nanoseconds.
+func (s *Sequencer) tickDuration() float64 {
+	return float64(time.Minute) / (s.bpm * float64(s.ppqn))
+}
+
+// notify wakes up the playback goroutine so it takes the changes into
+// account.
+func (s *Sequencer) notify() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
@@ -0,0 +1,281 @@
+package sequencer
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattetti/drumbeat"
+)
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// fakeClock only moves when advanced by the tests.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = timers
+}
+
+type chanSink struct {
+	events chan Event
+	err    error
+}
+
+func newChanSink() *chanSink {
+	return &chanSink{events: make(chan Event, 256)}
+}
+
+func (s *chanSink) Send(ev Event) error {
+	s.events <- ev
+	return s.err
+}
+
+// collect advances the clock until n events were received.
+func collect(t *testing.T, clk *fakeClock, sink *chanSink, n int) []Event {
+	t.Helper()
+	evs := []Event{}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(evs) < n {
+		select {
+		case ev := <-sink.events:
+			evs = append(evs, ev)
+			continue
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only received %d events out of %d", len(evs), n)
+		}
+		clk.Advance(5 * time.Millisecond)
+		time.Sleep(50 * time.Microsecond)
+	}
+	return evs
+}
+
+// waitState waits for the sequencer to reach the state.
+func waitState(t *testing.T, clk *fakeClock, seq *Sequencer, state State) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for seq.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the sequencer to be %s but it's %s", state, seq.State())
+		}
+		clk.Advance(5 * time.Millisecond)
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+func noteEvents(evs []Event) []drumbeat.NoteEvent {
+	out := make([]drumbeat.NoteEvent, len(evs))
+	for i, ev := range evs {
+		out[i] = ev.NoteEvent
+	}
+	return out
+}
+
+// tick is the duration of a tick at 120 BPM and the default PPQN.
+const tick = float64(time.Minute) / (120 * 96)
+
+func TestSequencer_play(t *testing.T) {
+	patterns := drumbeat.NewFromString(drumbeat.One16, `
+		[kick]	{C1}	x...x...;
+		[snare]	{D1}	....x...`)
+	want, _ := drumbeat.Timeline(patterns...)
+
+	clk := newFakeClock()
+	start := clk.Now()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	seq.SetPatterns(patterns...)
+	seq.Start()
+	got := collect(t, clk, sink, len(want))
+	waitState(t, clk, seq, Stopped)
+
+	if !reflect.DeepEqual(noteEvents(got), want) {
+		t.Fatalf("expected %+v\ngot %+v", want, noteEvents(got))
+	}
+	for _, ev := range got {
+		due := start.Add(time.Duration(float64(ev.Ticks) * tick))
+		if d := ev.Time.Sub(due); d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("expected %+v to be due at %v, got %v", ev.NoteEvent, due, ev.Time)
+		}
+	}
+	if got[0].Name != "kick" || got[len(got)-1].Name != "snare" {
+		t.Errorf("expected the events to be named after their pattern, got %q and %q", got[0].Name, got[len(got)-1].Name)
+	}
+	if pos := seq.Position(); pos != 0 {
+		t.Errorf("expected the sequencer to rewind, got position %d", pos)
+	}
+}
+
+func TestSequencer_loop(t *testing.T) {
+	patterns := drumbeat.NewFromString(drumbeat.One16, `[kick]	{C1}	x.x.`)
+	want, length := drumbeat.Timeline(patterns...)
+
+	clk := newFakeClock()
+	start := clk.Now()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	seq.SetPatterns(patterns...)
+	seq.SetLoop(true)
+	seq.Start()
+	got := collect(t, clk, sink, len(want)*2)
+	seq.Stop()
+
+	if !reflect.DeepEqual(noteEvents(got[len(want):]), want) {
+		t.Fatalf("expected the second loop to be %+v\ngot %+v", want, noteEvents(got[len(want):]))
+	}
+	due := start.Add(time.Duration(float64(length) * tick))
+	if d := got[len(want)].Time.Sub(due); d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("expected the second loop to start at %v, got %v", due, got[len(want)].Time)
+	}
+	if seq.State() != Stopped {
+		t.Errorf("expected the sequencer to be stopped, got %s", seq.State())
+	}
+}
+
+func TestSequencer_pauseAndSeek(t *testing.T) {
+	patterns := drumbeat.NewFromString(drumbeat.One16, `[kick]	{C1}	x=x=x=x=`)
+
+	clk := newFakeClock()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	seq.SetPatterns(patterns...)
+	seq.Start()
+	got := collect(t, clk, sink, 1)
+	if !got[0].On {
+		t.Fatalf("expected a note on, got %+v", got[0])
+	}
+	seq.Pause()
+	if seq.State() != Paused {
+		t.Fatalf("expected the sequencer to be paused, got %s", seq.State())
+	}
+	got = collect(t, clk, sink, 1)
+	if got[0].On || got[0].Key != 36 {
+		t.Fatalf("expected the playing note to be turned off when pausing, got %+v", got[0])
+	}
+	pos := seq.Position()
+	clk.Advance(time.Second)
+	if seq.Position() != pos {
+		t.Errorf("expected the position to stay at %d while paused, got %d", pos, seq.Position())
+	}
+
+	seq.Seek(96)
+	if seq.Position() != 96 {
+		t.Fatalf("expected the position to be 96, got %d", seq.Position())
+	}
+	seq.Start()
+	got = collect(t, clk, sink, 1)
+	if !got[0].On || got[0].Ticks != 96 {
+		t.Fatalf("expected the note at tick 96 to be played after seeking, got %+v", got[0])
+	}
+	seq.Stop()
+}
+
+func TestSequencer_tempo(t *testing.T) {
+	patterns := drumbeat.NewFromString(drumbeat.One4, `[kick]	{C1}	xxxx`)
+
+	clk := newFakeClock()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	if err := seq.SetTempo(0); err != drumbeat.ErrInvalidTempo {
+		t.Errorf("expected an invalid tempo error, got %v", err)
+	}
+	if err := seq.SetTempo(60); err != nil {
+		t.Fatal(err)
+	}
+	seq.SetPatterns(patterns...)
+	seq.Start()
+	got := collect(t, clk, sink, 3)
+	// on, off, on: a beat lasts a second at 60 BPM
+	if d := got[2].Time.Sub(got[0].Time); d < 999*time.Millisecond || d > 1001*time.Millisecond {
+		t.Errorf("expected a beat to last 1s, got %v", d)
+	}
+	if err := seq.SetTempo(120); err != nil {
+		t.Fatal(err)
+	}
+	got = collect(t, clk, sink, 4)
+	if d := got[3].Time.Sub(got[1].Time); d < 499*time.Millisecond || d > 501*time.Millisecond {
+		t.Errorf("expected a beat to last 500ms, got %v", d)
+	}
+	seq.Stop()
+}
+
+func TestSequencer_SetPatterns(t *testing.T) {
+	clk := newFakeClock()
+	sink := newChanSink()
+	seq := New(sink, clk)
+	seq.SetLoop(true)
+	seq.SetPatterns(drumbeat.NewFromString(drumbeat.One4, `[kick]	{C1}	x...`)...)
+	seq.Start()
+	got := collect(t, clk, sink, 1)
+	if got[0].Key != 36 {
+		t.Fatalf("expected the kick to be played, got %+v", got[0])
+	}
+
+	seq.SetPatterns(drumbeat.NewFromString(drumbeat.One4, `[snare]	{D1}	.x..`)...)
+	got = collect(t, clk, sink, 2)
+	if got[0].On || got[0].Key != 36 {
+		t.Errorf("expected the kick to be turned off, got %+v", got[0])
+	}
+	if !got[1].On || got[1].Key != 38 || got[1].Ticks != 96 {
+		t.Errorf("expected the new snare to be played, got %+v", got[1])
+	}
+	seq.Stop()
+}
+
+func TestSequencer_Err(t *testing.T) {
+	clk := newFakeClock()
+	sink := newChanSink()
+	sink.err = errors.New("port closed")
+	seq := New(sink, clk)
+	seq.SetPatterns(drumbeat.NewFromString(drumbeat.One4, `[kick]	{C1}	x`)...)
+	seq.Start()
+	collect(t, clk, sink, 1)
+	seq.Stop()
+	if seq.Err() != sink.err {
+		t.Errorf("expected the sink error, got %v", seq.Err())
+	}
+}
@@ -2,6 +2,7 @@ package drumbeat
 
 import (
 	"encoding/gob"
+	"encoding/json"
 	"io"
 )
 
@@ -26,3 +27,28 @@ func ReadFrom(r io.Reader) ([]*Pattern, error) {
 	}
 	return patterns, err
 }
+
+// WriteJSON serializes the passed patterns as JSON, only the pulses being
+// played are listed.
+func WriteJSON(w io.Writer, patterns ...*Pattern) error {
+	for _, p := range patterns {
+		p.compact()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(patterns)
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	return err
+}
+
+// ReadJSON reads patterns serialized by WriteJSON.
+func ReadJSON(r io.Reader) ([]*Pattern, error) {
+	var patterns []*Pattern
+	err := json.NewDecoder(r).Decode(&patterns)
+	for _, p := range patterns {
+		p.ReAlign()
+	}
+	return patterns, err
+}
@@ -38,3 +38,33 @@ func TestWriteTo(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []*Pattern
+	}{
+		{name: "nil pattern", patterns: nil},
+		{name: "patterns", patterns: NewFromString(One16, `
+			[kick]	{C1}	x...x(r3,50%)...;
+			[hihat]	{F#1}	(g50%)x.x(flam:8).x=..`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, p := range tt.patterns {
+				p.ReAlign()
+			}
+			w := &bytes.Buffer{}
+			if err := WriteJSON(w, tt.patterns...); err != nil {
+				t.Fatal(err)
+			}
+			patterns, err := ReadJSON(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Fatalf("Expected %#v to be %#v", patterns, tt.patterns)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package drumbeat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// SVGOptions are the settings used to render patterns to SVG.
+type SVGOptions struct {
+	// StepWidth and StepHeight are the size of a step, default to 20.
+	StepWidth  int
+	StepHeight int
+	// BarNumbers adds a row numbering the bars above the patterns.
+	BarNumbers bool
+}
+
+// svgCharWidth is the approximate width of a character of the labels.
+const svgCharWidth = 8
+
+// SaveAsSVG converts the patterns into a vector image using the same layout
+// as SaveAsPNG. The opacity of the hits reflects their velocity and the labels
+// are never truncated.
+func SaveAsSVG(w io.Writer, patterns []*Pattern, opts SVGOptions) error {
+	if len(patterns) < 1 {
+		return nil
+	}
+	for _, pat := range patterns {
+		pat.ReAlign()
+	}
+	if opts.StepWidth <= 0 {
+		opts.StepWidth = 20
+	}
+	if opts.StepHeight <= 0 {
+		opts.StepHeight = 20
+	}
+	stepWidth, stepHeight := opts.StepWidth, opts.StepHeight
+	nbrSteps := len(patterns[0].Pulses)
+
+	labelWidth := 7 * stepWidth
+	for _, pat := range patterns {
+		if l := len([]rune(pat.Name))*svgCharWidth + 10; l > labelWidth {
+			labelWidth = l
+		}
+	}
+	top := 0
+	if opts.BarNumbers {
+		top = stepHeight
+	}
+	width := labelWidth + nbrSteps*stepWidth
+	height := top + len(patterns)*stepHeight
+
+	hitFill := LightTheme.HitFill
+	hitStroke := LightTheme.HitStroke
+	labelBgColor := LightTheme.LabelBackground
+	// backgrounds/strokes alternate per row and per beat
+	bgColors := LightTheme.Backgrounds
+	strokeColors := LightTheme.GridStrokes
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(buf, `<rect x="0" y="0" width="%d" height="%d" fill="%s"/>`+"\n", labelWidth, height, svgColor(labelBgColor))
+
+	if opts.BarNumbers {
+		barSteps := int(patterns[0].Grid.StepsInBeat()) * 4
+		for i := 0; i < nbrSteps; i += barSteps {
+			fmt.Fprintf(buf, `<text x="%d" y="%d" font-family="monospace" font-size="13">%d</text>`+"\n",
+				labelWidth+i*stepWidth+3, stepHeight-6, i/barSteps+1)
+		}
+	}
+
+	for patternIDX, pattern := range patterns {
+		row := patternIDX % 2
+		stepsInBeat := int(pattern.Grid.StepsInBeat())
+		if stepsInBeat < 1 {
+			stepsInBeat = 1
+		}
+		y := top + patternIDX*stepHeight
+
+		// beat backgrounds and grid lines
+		for pulseIDX := 0; pulseIDX < len(pattern.Pulses); pulseIDX += stepsInBeat {
+			beat := (pulseIDX / stepsInBeat) % 2
+			steps := stepsInBeat
+			if pulseIDX+steps > len(pattern.Pulses) {
+				steps = len(pattern.Pulses) - pulseIDX
+			}
+			fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+				labelWidth+pulseIDX*stepWidth, y, steps*stepWidth, stepHeight, svgColor(bgColors[beat][row]))
+			for i := pulseIDX; i < pulseIDX+steps; i++ {
+				x := labelWidth + i*stepWidth
+				fmt.Fprintf(buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+					x, y, x, y+stepHeight, svgColor(strokeColors[beat][row]))
+			}
+		}
+		fmt.Fprintf(buf, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+			y, width, y, svgColor(strokeColors[0][0]))
+
+		// hits
+		for pulseIDX, pulse := range pattern.Pulses {
+			if pulse == nil {
+				continue
+			}
+			x := labelWidth + pulseIDX*stepWidth
+			fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%.2f" stroke="%s"/>`+"\n",
+				x, y, stepWidth, stepHeight, svgColor(hitFill), VelocityOpacity(pulse.Velocity), svgColor(hitStroke))
+			writeSVGSubHits(buf, pulse, x, y, stepWidth, stepHeight, hitStroke)
+		}
+
+		fmt.Fprintf(buf, `<text x="5" y="%d" font-family="monospace" font-size="13">`, y+stepHeight-6)
+		xml.EscapeText(buf, []byte(pattern.Name))
+		buf.WriteString("</text>\n")
+	}
+	// bottom grid line
+	fmt.Fprintf(buf, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+		height, width, height, svgColor(strokeColors[0][0]))
+	buf.WriteString("</svg>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeSVGSubHits draws the ratchet divisions and the grace notes inside the
+// cell of a pulse.
+func writeSVGSubHits(buf *bytes.Buffer, pulse *Pulse, x, y, stepWidth, stepHeight int, col color.NRGBA) {
+	if n := int(pulse.Ratchets); n > 1 {
+		for i := 1; i < n; i++ {
+			subX := x + (i * stepWidth / n)
+			fmt.Fprintf(buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+				subX, y+stepHeight/4, subX, y+stepHeight-stepHeight/4, svgColor(col))
+		}
+	}
+	graces := 0
+	switch pulse.Ornament {
+	case Flam:
+		graces = 1
+	case Drag:
+		graces = 2
+	}
+	for i := 0; i < graces; i++ {
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="3" height="3" fill="%s"/>`+"\n",
+			x+2+(i*4), y+stepHeight-6, svgColor(col))
+	}
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
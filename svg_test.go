@@ -0,0 +1,67 @@
+package drumbeat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSaveAsSVG(t *testing.T) {
+	patterns := NewFromString(One16, `
+		[a very long name for a closed hihat]	x.x.;
+		[snare & clap]	..x(r3)x(flam)`)
+	patterns[0].Pulses[0].Velocity = 127
+	patterns[0].Pulses[2].Velocity = 64
+	tests := []struct {
+		name     string
+		opts     SVGOptions
+		contains []string
+		missing  []string
+	}{
+		{name: "default",
+			contains: []string{
+				`width="610" height="40"`,
+				"a very long name for a closed hihat</text>",
+				"snare &amp; clap</text>",
+				`fill-opacity="1.00"`,
+				`fill-opacity="0.63"`,
+			},
+			missing: []string{">1</text>"},
+		},
+		{name: "bar numbers",
+			opts:     SVGOptions{StepWidth: 10, StepHeight: 10, BarNumbers: true},
+			contains: []string{`width="450" height="30"`, ">1</text>"},
+			missing:  []string{">2</text>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := SaveAsSVG(buf, patterns, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			// make sure the document is well formed
+			d := xml.NewDecoder(strings.NewReader(out))
+			for {
+				if _, err := d.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("invalid svg: %v", err)
+				}
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out, s) {
+					t.Errorf("expected the svg to contain %q", s)
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(out, s) {
+					t.Errorf("didn't expect the svg to contain %q", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package drumbeat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-audio/midi"
+)
+
+// TermStyle is the way the hits are printed in a terminal.
+type TermStyle int
+
+const (
+	// TermASCII prints plain ASCII: o, x and X for soft, normal and accented
+	// hits.
+	TermASCII TermStyle = iota
+	// TermShades prints the hits as unicode shade characters.
+	TermShades
+	// TermColors prints the hits as blocks using the 256 ANSI colors.
+	TermColors
+)
+
+// TermOptions are the settings used to print patterns in a terminal.
+type TermOptions struct {
+	Style TermStyle
+	// ShowPlayhead highlights the Playhead step (0 based).
+	ShowPlayhead bool
+	Playhead     int
+}
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiReverse = "\x1b[7m"
+)
+
+var termShades = []rune{'░', '▒', '▓', '█'}
+
+// WriteTerm prints the patterns as an aligned grid with their name and key.
+// Bars are separated by | and beats by : and the first line numbers the bars.
+// When not using colors, the playhead is marked by a ^ on the last line.
+func WriteTerm(w io.Writer, patterns []*Pattern, opts TermOptions) error {
+	if len(patterns) < 1 {
+		return nil
+	}
+	var nbrSteps, nameWidth int
+	for _, p := range patterns {
+		p.ReAlign()
+		if len(p.Pulses) > nbrSteps {
+			nbrSteps = len(p.Pulses)
+		}
+		if l := utf8.RuneCountInString(p.Name); l > nameWidth {
+			nameWidth = l
+		}
+	}
+	stepsInBeat := int(patterns[0].Grid.StepsInBeat())
+	if stepsInBeat < 1 {
+		stepsInBeat = 1
+	}
+	barSteps := stepsInBeat * 4
+	// name, note and key number
+	prefixWidth := nameWidth + 10
+
+	buf := &bytes.Buffer{}
+	// bar numbers
+	header := []byte(strings.Repeat(" ", prefixWidth) + termGrid(nbrSteps, stepsInBeat, func(int) string { return " " }))
+	for bar := 0; bar*barSteps < nbrSteps; bar++ {
+		col := prefixWidth + termColumn(bar*barSteps, stepsInBeat)
+		for i, c := range []byte(fmt.Sprint(bar + 1)) {
+			if col+i < len(header) {
+				header[col+i] = c
+			}
+		}
+	}
+	buf.WriteString(strings.TrimRight(string(header), " ") + "\n")
+
+	for _, p := range patterns {
+		name := p.Name + strings.Repeat(" ", nameWidth-utf8.RuneCountInString(p.Name))
+		fmt.Fprintf(buf, "%s %-4s %3d ", name, midi.NoteToName(p.Key), p.Key)
+		buf.WriteString(termGrid(nbrSteps, stepsInBeat, func(i int) string {
+			var pulse *Pulse
+			if i < len(p.Pulses) {
+				pulse = p.Pulses[i]
+			}
+			cell := termCell(pulse, opts.Style)
+			if opts.ShowPlayhead && i == opts.Playhead && opts.Style != TermASCII {
+				cell = ansiReverse + cell + ansiReset
+			}
+			return cell
+		}))
+		buf.WriteString("\n")
+	}
+
+	if opts.ShowPlayhead && opts.Style == TermASCII && opts.Playhead >= 0 && opts.Playhead < nbrSteps {
+		col := prefixWidth + termColumn(opts.Playhead, stepsInBeat)
+		buf.WriteString(strings.Repeat(" ", col) + "^\n")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// termGrid joins the cells of the steps with the beat and bar separators.
+func termGrid(nbrSteps, stepsInBeat int, cell func(i int) string) string {
+	var sb strings.Builder
+	for i := 0; i < nbrSteps; i++ {
+		switch {
+		case i%(stepsInBeat*4) == 0:
+			sb.WriteByte('|')
+		case i%stepsInBeat == 0:
+			sb.WriteByte(':')
+		}
+		sb.WriteString(cell(i))
+	}
+	sb.WriteByte('|')
+	return sb.String()
+}
+
+// termColumn returns the column of a step in the grid.
+func termColumn(step, stepsInBeat int) int {
+	// each beat starts with a separator
+	return step + step/stepsInBeat + 1
+}
+
+// termCell returns the character representing a pulse.
+func termCell(pulse *Pulse, style TermStyle) string {
+	if pulse == nil || pulse.Velocity == 0 {
+		if style == TermShades {
+			return "·"
+		}
+		return "."
+	}
+	vel := int(pulse.Velocity)
+	if vel > 127 {
+		vel = 127
+	}
+	switch style {
+	case TermShades:
+		return string(termShades[vel*len(termShades)/128])
+	case TermColors:
+		// grayscale ramp, from dark gray to white
+		return fmt.Sprintf("\x1b[38;5;%dm█%s", 238+vel*17/127, ansiReset)
+	}
+	switch {
+	case vel < 64:
+		return "o"
+	case vel >= 100:
+		return "X"
+	}
+	return "x"
+}
@@ -0,0 +1,70 @@
+package drumbeat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTerm(t *testing.T) {
+	newPatterns := func() []*Pattern {
+		patterns := NewFromString(One8, `
+			[kick]	{C1}	x...x...;
+			[hihat]	{F#1}	xxxxxxxx`)
+		patterns[1].Pulses[0].Velocity = 110
+		patterns[1].Pulses[1].Velocity = 40
+		return patterns
+	}
+	tests := []struct {
+		name string
+		opts TermOptions
+		want string
+	}{
+		{name: "ascii",
+			opts: TermOptions{},
+			want: `               |1 :  :  :  |
+kick  C1    36 |x.:..:x.:..|
+hihat F#1   42 |Xo:xx:xx:xx|
+`},
+		{name: "ascii playhead",
+			opts: TermOptions{ShowPlayhead: true, Playhead: 3},
+			want: `               |1 :  :  :  |
+kick  C1    36 |x.:..:x.:..|
+hihat F#1   42 |Xo:xx:xx:xx|
+                    ^
+`},
+		{name: "shades",
+			opts: TermOptions{Style: TermShades},
+			want: `               |1 :  :  :  |
+kick  C1    36 |▓·:··:▓·:··|
+hihat F#1   42 |█▒:▓▓:▓▓:▓▓|
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := WriteTerm(buf, newPatterns(), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("colors", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := WriteTerm(buf, newPatterns(), TermOptions{Style: TermColors, ShowPlayhead: true, Playhead: 0}); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		for _, s := range []string{"\x1b[7m\x1b[38;5;252m█\x1b[0m\x1b[0m", "\x1b[38;5;250m█\x1b[0m"} {
+			if !strings.Contains(out, s) {
+				t.Errorf("expected the output to contain %q, got %q", s, out)
+			}
+		}
+		if strings.Contains(out, "^") {
+			t.Error("didn't expect the ascii playhead marker when using colors")
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package drumbeat
+
+import (
+	"sort"
+
+	"github.com/go-audio/midi"
+)
+
+// NoteEvent is a note on or note off event at an absolute position.
+type NoteEvent struct {
+	// Ticks is the position of the event from the start of the patterns.
+	Ticks uint64
+	// Key is the MIDI key of the note.
+	Key int
+	// Velocity of the note, 0 for note offs.
+	Velocity uint8
+	// On is true for note ons and false for note offs.
+	On bool
+	// Pattern is the index of the pattern triggering the note.
+	Pattern int
+}
+
+// Timeline converts the patterns into note events sorted by position, the
+// note offs being sent before the note ons happening at the same tick. The
+// pulses are placed at their ticks plus their nudge and keep their
+// ratchets, grace notes, gates and choke groups, the grace notes
+// which would be played before the first tick are dropped. The returned
+// length is the length in ticks of the longest pattern. The patterns are
+// expected to share the same PPQN.
+func Timeline(patterns ...*Pattern) (events []NoteEvent, length uint64) {
+	var nbrSteps int
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		p.ReAlign()
+		if len(p.Pulses) > nbrSteps {
+			nbrSteps = len(p.Pulses)
+		}
+	}
+	evs, length := noteEvents(patterns, nbrSteps, (*Pattern).StepSize)
+	sortNoteEvs(evs)
+	events = make([]NoteEvent, len(evs))
+	for i, ev := range evs {
+		events[i] = NoteEvent{Ticks: ev.tick, Key: ev.key, Velocity: ev.vel, On: ev.on, Pattern: ev.pattern}
+	}
+	return events, length
+}
+
+// noteEv is an absolute note on/off event.
+type noteEv struct {
+	tick    uint64
+	key     int
+	vel     uint8
+	on      bool
+	pattern int
+}
+
+// noteEvents converts the first nbrSteps steps of the patterns to unsorted
+// note events. stepTicks returns the size of a pattern's steps in the
+// output, the pulse lengths are scaled accordingly. The returned end is the
+// position of the end of the longest pattern.
+func noteEvents(patterns []*Pattern, nbrSteps int, stepTicks func(*Pattern) uint64) (evs []noteEv, end uint64) {
+	keyOf := keysOf(patterns)
+
+	// tick at which the notes were last triggered
+	noteStarts := map[int]uint64{}
+	// index of the pending note off event of each key
+	noteOffs := map[int]int{}
+	// cut ends the note playing on the key at the passed tick
+	cut := func(key int, tick uint64) {
+		idx, ok := noteOffs[key]
+		if !ok || evs[idx].tick <= tick {
+			return
+		}
+		if start := noteStarts[key]; tick < start {
+			tick = start
+		}
+		evs[idx].tick = tick
+	}
+
+	for _, t := range patterns {
+		if t == nil {
+			continue
+		}
+		steps := len(t.Pulses)
+		if steps > nbrSteps {
+			steps = nbrSteps
+		}
+		if e := uint64(steps) * stepTicks(t); e > end {
+			end = e
+		}
+	}
+
+	// loop through all the steps, one step at a time and inject
+	// all track states inside the same channel.
+	for i := 0; i < nbrSteps; i++ {
+		for n, t := range patterns {
+			if t == nil || len(t.Pulses) <= i || t.Pulses[i] == nil {
+				continue
+			}
+			pulse := t.Pulses[i]
+			notePitch := keyOf(n)
+			currentStepDuration := stepTicks(t)
+			stepStart := uint64(i) * currentStepDuration
+
+			// The step position is quantized but we keep the pulse's microtiming,
+			// its position within the step and its nudge, and scale its length
+			// to the output steps.
+			length := pulse.Length(t.StepSize(), t.Gate)
+			if stepSize := t.StepSize(); stepSize > 0 {
+				if gridTick := uint64(i) * stepSize; pulse.Ticks > gridTick && pulse.Ticks < gridTick+stepSize {
+					stepStart += (pulse.Ticks - gridTick) * currentStepDuration / stepSize
+				}
+				length = length * currentStepDuration / stepSize
+			}
+			start := nudge(stepStart, int64(pulse.Nudge))
+			if length < 1 {
+				length = 1
+			}
+			hits := pulse.hitsAt(start, currentStepDuration)
+			firstTick := nudge(start, hits[0].Offset)
+
+			// cut the notes of the other patterns in the choke group
+			if t.ChokeGroup != 0 {
+				for m, other := range patterns {
+					if other == nil {
+						continue
+					}
+					otherPitch := keyOf(m)
+					if m == n || other.ChokeGroup != t.ChokeGroup || otherPitch == notePitch {
+						continue
+					}
+					if noteStarts[otherPitch] < firstTick {
+						cut(otherPitch, firstTick)
+					}
+				}
+			}
+
+			// Ratchets and grace notes last until the next hit, the last hit
+			// is played for the gate length.
+			for _, h := range hits {
+				tick := nudge(start, h.Offset)
+				// retrigger
+				cut(notePitch, tick)
+				noteLen := length
+				if h.Duration > 0 && h.Duration < noteLen {
+					noteLen = h.Duration
+				}
+				evs = append(evs,
+					noteEv{tick: tick, key: notePitch, vel: h.Velocity, on: true, pattern: n},
+					noteEv{tick: tick + noteLen, key: notePitch, pattern: n})
+				noteOffs[notePitch] = len(evs) - 1
+				noteStarts[notePitch] = tick
+			}
+		}
+	}
+	return evs, end
+}
+
+// keysOf returns a function giving the MIDI key of the pattern at the passed
+// index. If the first pattern and another one have their key at C-1, we
+// assume that the keys weren't set and map the patterns from C1.
+func keysOf(patterns []*Pattern) func(n int) int {
+	areKeysSet := true
+	startingKey := midi.KeyInt("C", 1)
+	for i, t := range patterns {
+		if i > 0 && t != nil && patterns[0] != nil && patterns[0].Key == 0 && t.Key == 0 {
+			areKeysSet = false
+		}
+	}
+	return func(n int) int {
+		if !areKeysSet && patterns[n].Key == 0 {
+			return startingKey + n
+		}
+		return patterns[n].Key
+	}
+}
+
+// sortNoteEvs sorts the events by position, note offs are sent before note
+// ons happening at the same time so retriggers don't get cut.
+func sortNoteEvs(evs []noteEv) {
+	sort.SliceStable(evs, func(i, j int) bool {
+		if evs[i].tick == evs[j].tick {
+			return !evs[i].on && evs[j].on
+		}
+		return evs[i].tick < evs[j].tick
+	})
+}
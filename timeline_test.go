@@ -0,0 +1,87 @@
+package drumbeat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	tests := []struct {
+		name       string
+		grid       GridRes
+		str        string
+		wantEvents []NoteEvent
+		wantLength uint64
+	}{
+		{name: "empty bar", grid: One16, str: "", wantEvents: []NoteEvent{}, wantLength: 384},
+		{name: "retrigger",
+			grid: One8,
+			str:  "[kick]\t{C1}\tx=x.",
+			wantEvents: []NoteEvent{
+				{Ticks: 0, Key: 36, Velocity: 90, On: true},
+				{Ticks: 96, Key: 36},
+				{Ticks: 96, Key: 36, Velocity: 90, On: true},
+				{Ticks: 144, Key: 36},
+			},
+			wantLength: 384,
+		},
+		{name: "several patterns",
+			grid: One4,
+			str: `[kick]	{C1}	x.;
+				[snare]	{D1}	.x`,
+			wantEvents: []NoteEvent{
+				{Ticks: 0, Key: 36, Velocity: 90, On: true},
+				{Ticks: 96, Key: 36},
+				{Ticks: 96, Key: 38, Velocity: 90, On: true, Pattern: 1},
+				{Ticks: 192, Key: 38, Pattern: 1},
+			},
+			wantLength: 384,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, length := Timeline(NewFromString(tt.grid, tt.str)...)
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("expected events %+v, got %+v", tt.wantEvents, events)
+			}
+			if length != tt.wantLength {
+				t.Errorf("expected a length of %d, got %d", tt.wantLength, length)
+			}
+		})
+	}
+}
+
+func TestTimeline_graceNotesAtStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		wantOns int
+	}{
+		{name: "flam", str: "x(flam)...", wantOns: 1},
+		{name: "drag", str: "x(drag)...", wantOns: 1},
+		{name: "long drag", str: ".x(drag:40)..", wantOns: 2},
+		{name: "flam on the second step", str: ".x(flam)..", wantOns: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _ := Timeline(NewFromString(One16, tt.str)...)
+			var ons, offs int
+			playing := 0
+			for _, ev := range events {
+				if ev.On {
+					ons++
+					playing++
+				} else {
+					offs++
+					playing--
+				}
+				if playing < 0 || playing > 1 {
+					t.Fatalf("unbalanced events %+v", events)
+				}
+			}
+			if ons != offs || ons != tt.wantOns {
+				t.Errorf("expected %d note ons and offs, got %d ons and %d offs", tt.wantOns, ons, offs)
+			}
+		})
+	}
+}
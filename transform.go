@@ -0,0 +1,64 @@
+package drumbeat
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Swing delays every other step of the patterns, using the MPC convention: the
+// amount is the position in percent of the second step within each pair of
+// steps. 50 is straight, 66 is close to a triplet feel and the amount is
+// limited to 75. The nudges of the delayed steps are replaced.
+func Swing(amount float64, patterns ...*Pattern) {
+	amount = math.Max(50, math.Min(75, amount))
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		delay := 2 * float64(p.StepSize()) * (amount - 50) / 100
+		for i := 1; i < len(p.Pulses); i += 2 {
+			if pulse := p.Pulses[i]; pulse != nil {
+				pulse.Nudge = int16(math.Floor(delay + 0.5))
+			}
+		}
+	}
+}
+
+// HumanizeOptions are the random deviations applied by Humanize.
+type HumanizeOptions struct {
+	// Timing is the maximum nudge in ticks added to or removed from the
+	// pulses.
+	Timing int
+	// Velocity is the maximum change of velocity.
+	Velocity int
+	// Seed is used to make the changes reproducible.
+	Seed int64
+}
+
+// Humanize randomly moves the pulses and changes their velocities to make the
+// patterns sound less mechanical. The deviations are added to the existing
+// nudges so it can be used after Swing.
+func Humanize(opts HumanizeOptions, patterns ...*Pattern) {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	deviation := func(max int) int {
+		if max <= 0 {
+			return 0
+		}
+		return rnd.Intn(2*max+1) - max
+	}
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		for _, pulse := range p.Pulses {
+			if pulse == nil {
+				continue
+			}
+			nudge := int(pulse.Nudge) + deviation(opts.Timing)
+			pulse.Nudge = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, float64(nudge))))
+			if pulse.Velocity > 0 {
+				pulse.Velocity = scaleVelocity(int(pulse.Velocity) + deviation(opts.Velocity))
+			}
+		}
+	}
+}
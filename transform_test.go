@@ -0,0 +1,64 @@
+package drumbeat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSwing(t *testing.T) {
+	tests := []struct {
+		name   string
+		grid   GridRes
+		amount float64
+		want   []int16
+	}{
+		{name: "straight", grid: One16, amount: 50, want: []int16{0, 0, 0, 0}},
+		{name: "16th", grid: One16, amount: 66, want: []int16{0, 8, 0, 8}},
+		{name: "8th", grid: One8, amount: 60, want: []int16{0, 10, 0, 10}},
+		{name: "clamped", grid: One16, amount: 90, want: []int16{0, 12, 0, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewFromString(tt.grid, "xxxx")[0]
+			Swing(tt.amount, p)
+			got := make([]int16, len(tt.want))
+			for i := range got {
+				got[i] = p.Pulses[i].Nudge
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the nudges to be %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	humanize := func(seed int64) []*Pattern {
+		patterns := NewFromString(One16, "x.x.x.x.x.x.x.x.")
+		Swing(66, patterns...)
+		Humanize(HumanizeOptions{Timing: 4, Velocity: 10, Seed: seed}, patterns...)
+		return patterns
+	}
+	patterns := humanize(1)
+	var changed bool
+	for i, pulse := range patterns[0].Pulses {
+		if pulse == nil {
+			continue
+		}
+		if pulse.Nudge < -4 || pulse.Nudge > 4 {
+			t.Errorf("[%d] expected the nudge to be within 4 ticks, got %d", i, pulse.Nudge)
+		}
+		if pulse.Velocity < 80 || pulse.Velocity > 100 {
+			t.Errorf("[%d] expected the velocity to be within 10 of 90, got %d", i, pulse.Velocity)
+		}
+		if pulse.Nudge != 0 || pulse.Velocity != 90 {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Error("expected the pulses to be humanized")
+	}
+	if !reflect.DeepEqual(patterns, humanize(1)) {
+		t.Error("expected the same seed to give the same result")
+	}
+}
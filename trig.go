@@ -0,0 +1,253 @@
+package drumbeat
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Condition is a trig condition restricting the loops in which a pulse is
+// triggered. It follows the Elektron conventions:
+//
+//	A:B    triggered on the Ath loop out of every B loops (1:2, 3:4...)
+//	1st    first loop only, !1st every loop but the first
+//	fill   fill loops only, !fill every loop but the fill loops
+//	pre    when the previous conditional pulse of the same pattern was
+//	       triggered, !pre when it wasn't
+//	nei    when the previous conditional pulse of the neighbor pattern (the
+//	       pattern before in the list) was triggered, !nei when it wasn't
+//
+// An empty condition is always true.
+type Condition string
+
+const (
+	CondNone     Condition = ""
+	CondFirst    Condition = "1st"
+	CondNotFirst Condition = "!1st"
+	CondFill     Condition = "fill"
+	CondNotFill  Condition = "!fill"
+	CondPre      Condition = "pre"
+	CondNotPre   Condition = "!pre"
+	CondNei      Condition = "nei"
+	CondNotNei   Condition = "!nei"
+)
+
+// ParseCondition parses and validates a condition.
+func ParseCondition(s string) (Condition, error) {
+	c := Condition(strings.ToLower(strings.TrimSpace(s)))
+	switch c {
+	case CondNone, CondFirst, CondNotFirst, CondFill, CondNotFill, CondPre, CondNotPre, CondNei, CondNotNei:
+		return c, nil
+	}
+	if a, b, ok := c.ratio(); ok && a >= 1 && a <= b {
+		return c, nil
+	}
+	return CondNone, fmt.Errorf("invalid trig condition %q", s)
+}
+
+// ratio returns the A and B values of a A:B condition.
+func (c Condition) ratio() (a, b int, ok bool) {
+	parts := strings.Split(string(c), ":")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	a, errA := strconv.Atoi(parts[0])
+	b, errB := strconv.Atoi(parts[1])
+	if errA != nil || errB != nil || b < 1 {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// trigState is the state used to evaluate the conditions while rendering.
+type trigState struct {
+	loop int
+	fill bool
+	// last conditional results per pattern
+	results []bool
+}
+
+// eval returns true if the condition is met for the pattern at index n.
+func (c Condition) eval(st *trigState, n int) bool {
+	switch c {
+	case CondNone:
+		return true
+	case CondFirst:
+		return st.loop == 0
+	case CondNotFirst:
+		return st.loop != 0
+	case CondFill:
+		return st.fill
+	case CondNotFill:
+		return !st.fill
+	case CondPre:
+		return st.results[n]
+	case CondNotPre:
+		return !st.results[n]
+	case CondNei, CondNotNei:
+		nei := false
+		if n > 0 {
+			nei = st.results[n-1]
+		}
+		if c == CondNei {
+			return nei
+		}
+		return !nei
+	}
+	if a, b, ok := c.ratio(); ok {
+		return st.loop%b == a-1
+	}
+	return false
+}
+
+// RenderOptions are the settings used to render loops.
+type RenderOptions struct {
+	// Loops is the number of times the patterns are played, defaults to 1.
+	Loops int
+	// Fills are the indexes (0 based) of the loops played in fill mode.
+	Fills []int
+	// Seed is used to resolve the probabilities.
+	Seed int64
+}
+
+// Render plays the patterns over multiple loops resolving the probabilities
+// and conditions of their pulses. It returns new patterns containing the
+// concrete pulses, ready to be converted to MIDI or audio. The rendered
+// patterns keep the settings of the original ones, nil patterns are rendered
+// as nil. The patterns passed in aren't modified.
+//
+// A loop lasts as long as the longest pattern, the shorter patterns repeat
+// within each loop and their A:B and 1st conditions count their own
+// repetitions. The pulses are evaluated in time order, so patterns on
+// different grids are compared by ticks and not by step index.
+func Render(opts RenderOptions, patterns ...*Pattern) []*Pattern {
+	if len(patterns) < 1 {
+		return nil
+	}
+	if opts.Loops < 1 {
+		opts.Loops = 1
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	fills := map[int]bool{}
+	for _, l := range opts.Fills {
+		fills[l] = true
+	}
+
+	aligned := make([]*Pattern, len(patterns))
+	var loopTicks uint64
+	for n, p := range patterns {
+		if p == nil {
+			continue
+		}
+		a := *p
+		a.Pulses = append(Pulses(nil), p.Pulses...)
+		a.countCache = 0
+		a.ReAlign()
+		aligned[n] = &a
+		if l := uint64(len(a.Pulses)) * a.StepSize(); l > loopTicks {
+			loopTicks = l
+		}
+	}
+
+	rendered := make([]*Pattern, len(patterns))
+	for n, p := range aligned {
+		if p == nil {
+			continue
+		}
+		r := *p
+		r.Pulses = make(Pulses, loopTicks/p.StepSize()*uint64(opts.Loops))
+		r.countCache = 0
+		rendered[n] = &r
+	}
+
+	st := &trigState{results: make([]bool, len(patterns))}
+	repeats := make([]int, len(patterns))
+	for loop := 0; loop < opts.Loops; loop++ {
+		st.fill = fills[loop]
+		offset := uint64(loop) * loopTicks
+		for _, t := range loopTrigs(aligned, loopTicks) {
+			n, pulse := t.pattern, t.pulse
+			st.loop = repeats[n] + t.repeat
+			trig := pulse.Condition.eval(st, n)
+			if pulse.Probability > 0 && pulse.Probability < 100 {
+				trig = trig && rnd.Intn(100) < int(pulse.Probability)
+			}
+			// pre and nei conditions refer to the last conditional pulse
+			if pulse.Condition != CondNone || (pulse.Probability > 0 && pulse.Probability < 100) {
+				if pulse.Condition != CondPre && pulse.Condition != CondNotPre &&
+					pulse.Condition != CondNei && pulse.Condition != CondNotNei {
+					st.results[n] = trig
+				}
+			}
+			if !trig {
+				continue
+			}
+			resolved := *pulse
+			resolved.Ticks = pulse.Ticks + offset + t.start
+			resolved.Probability = 0
+			resolved.Condition = CondNone
+			stepSize := aligned[n].StepSize()
+			rendered[n].Pulses[(offset+t.start)/stepSize+uint64(t.step)] = &resolved
+		}
+		for n, p := range aligned {
+			if p != nil {
+				repeats[n] += repetitions(p, loopTicks)
+			}
+		}
+	}
+	return rendered
+}
+
+// loopTrig is a pulse to evaluate during a loop.
+type loopTrig struct {
+	pattern int
+	pulse   *Pulse
+	// repeat is the repetition of the pattern within the loop, starting at
+	// start ticks.
+	repeat int
+	start  uint64
+	step   int
+}
+
+// loopTrigs lists the pulses of the aligned patterns played during a loop of
+// loopTicks, sorted by time then by pattern.
+func loopTrigs(patterns []*Pattern, loopTicks uint64) []loopTrig {
+	var trigs []loopTrig
+	for n, p := range patterns {
+		if p == nil {
+			continue
+		}
+		stepSize := p.StepSize()
+		length := uint64(len(p.Pulses)) * stepSize
+		for r := 0; r < repetitions(p, loopTicks); r++ {
+			start := uint64(r) * length
+			for i, pulse := range p.Pulses {
+				if pulse == nil || start+uint64(i)*stepSize >= loopTicks {
+					continue
+				}
+				trigs = append(trigs, loopTrig{pattern: n, pulse: pulse, repeat: r, start: start, step: i})
+			}
+		}
+	}
+	sort.SliceStable(trigs, func(i, j int) bool {
+		ti := trigs[i].start + uint64(trigs[i].step)*patterns[trigs[i].pattern].StepSize()
+		tj := trigs[j].start + uint64(trigs[j].step)*patterns[trigs[j].pattern].StepSize()
+		if ti != tj {
+			return ti < tj
+		}
+		return trigs[i].pattern < trigs[j].pattern
+	})
+	return trigs
+}
+
+// repetitions returns the number of times the aligned pattern starts within
+// a loop of loopTicks.
+func repetitions(p *Pattern, loopTicks uint64) int {
+	length := uint64(len(p.Pulses)) * p.StepSize()
+	if length == 0 {
+		return 0
+	}
+	return int((loopTicks + length - 1) / length)
+}
@@ -0,0 +1,185 @@
+package drumbeat
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Condition
+		wantErr bool
+	}{
+		{in: "", want: CondNone},
+		{in: "1:2", want: "1:2"},
+		{in: "3:4", want: "3:4"},
+		{in: "FILL", want: CondFill},
+		{in: " !1st ", want: CondNotFirst},
+		{in: "nei", want: CondNei},
+		{in: "5:4", wantErr: true},
+		{in: "0:2", wantErr: true},
+		{in: "1:0", wantErr: true},
+		{in: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCondition(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCondition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		opts  RenderOptions
+		wants []string
+	}{
+		{name: "no conditions",
+			str:   "x...x...",
+			opts:  RenderOptions{Loops: 2},
+			wants: []string{"x...x...x...x..."}},
+		{name: "ratios",
+			str:   "x(1:2)...x(2:2)...x(3:4)...x(4:4)...",
+			opts:  RenderOptions{Loops: 4},
+			wants: []string{"x...................x...........x.......x...........x.......x..."}},
+		{name: "first loop",
+			str:   "x(1st)...x(!1st)...",
+			opts:  RenderOptions{Loops: 3},
+			wants: []string{"x...........x.......x..."}},
+		{name: "fills",
+			str:   "x(fill)...x(!fill)...",
+			opts:  RenderOptions{Loops: 3, Fills: []int{1}},
+			wants: []string{"....x...x...........x..."}},
+		{name: "pre",
+			str:   "x(1:2)...x(pre)...x(!pre)...",
+			opts:  RenderOptions{Loops: 2},
+			wants: []string{"x...x...................x......."}},
+		{name: "nei",
+			str:   "x(2:2).......;....x(nei)...;x(1:2).......;....x(!nei)...",
+			opts:  RenderOptions{Loops: 2},
+			wants: []string{"........x.......", "............x...", "x...............", "............x..."}},
+		{name: "always and never",
+			str:   "x(100%)...x(0%)...",
+			opts:  RenderOptions{Loops: 1},
+			wants: []string{"x......."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := NewFromString(One8, tt.str)
+			rendered := Render(tt.opts, patterns...)
+			if len(rendered) != len(tt.wants) {
+				t.Fatalf("expected %d patterns, got %d", len(tt.wants), len(rendered))
+			}
+			for i, p := range rendered {
+				if p.Pulses.String() != tt.wants[i] {
+					t.Errorf("[%d] expected\n%s, got\n%s", i, tt.wants[i], p.Pulses)
+				}
+				for j, pulse := range p.Pulses {
+					if pulse == nil {
+						continue
+					}
+					if pulse.Ticks != uint64(j)*p.StepSize() {
+						t.Errorf("[%d] expected pulse %d to be at %d ticks, got %d", i, j, uint64(j)*p.StepSize(), pulse.Ticks)
+					}
+					if pulse.Condition != CondNone || pulse.Probability != 0 {
+						t.Errorf("[%d] expected pulse %d to be resolved", i, j)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("probability", func(t *testing.T) {
+		patterns := NewFromString(One16, "x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)x(50%)")
+		a := Render(RenderOptions{Loops: 8, Seed: 3}, patterns...)[0]
+		b := Render(RenderOptions{Loops: 8, Seed: 3}, patterns...)[0]
+		if a.Pulses.String() != b.Pulses.String() {
+			t.Fatalf("expected the same seed to render the same pulses")
+		}
+		if n := a.ActivePulses(); n < 32 || n > 96 {
+			t.Errorf("expected roughly half of the 128 pulses to be triggered, got %d", n)
+		}
+	})
+
+	t.Run("pattern settings", func(t *testing.T) {
+		patterns := NewFromString(One8, "[hihat open]\t{A#1}\t(g50%)x...")
+		patterns[0].ChokeGroup = 1
+		rendered := Render(RenderOptions{Loops: 2}, patterns...)[0]
+		if rendered.ChokeGroup != 1 || rendered.Name != "hihat open" || rendered.Key != 46 {
+			t.Errorf("expected the settings of the pattern to be kept, got %+v", rendered)
+		}
+		if want := (Gate{Mode: GatePercent, Value: 50}); rendered.Gate != want {
+			t.Errorf("expected the gate %+v, got %+v", want, rendered.Gate)
+		}
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		patterns := NewFromString(One8, "@bpm=120\nx...")
+		rendered := Render(RenderOptions{Loops: 2}, patterns...)[0]
+		if rendered.Meta != patterns[0].Meta || rendered.Meta == nil || rendered.Meta.BPM != 120 {
+			t.Errorf("expected the metadata to be kept, got %+v", rendered.Meta)
+		}
+	})
+
+	t.Run("inputs untouched", func(t *testing.T) {
+		p := NewFromString(One8, "x(1:2)..")[0]
+		Render(RenderOptions{Loops: 2}, p)
+		if p.Pulses.String() != "x.." || p.Pulses[0].Condition != "1:2" {
+			t.Errorf("expected the pattern not to be modified, got %s", p.Pulses)
+		}
+	})
+
+	t.Run("shorter patterns", func(t *testing.T) {
+		patterns := NewFromString(One8, "x.......x.......;x(1:2).......")
+		rendered := Render(RenderOptions{Loops: 2}, patterns...)
+		if want := "x...............x..............."; rendered[1].Pulses.String() != want {
+			t.Errorf("expected the shorter pattern to repeat within the loops\n%s, got\n%s", want, rendered[1].Pulses)
+		}
+		if pulse := rendered[1].Pulses[16]; pulse == nil || pulse.Ticks != 16*48 {
+			t.Errorf("expected the pulse of the third repetition to be at %d ticks, got %+v", 16*48, pulse)
+		}
+	})
+
+	t.Run("different grids", func(t *testing.T) {
+		eighths := NewFromString(One8, "...x(1st)")[0]
+		sixteenths := NewFromString(One16, "....x(nei)")[0]
+		rendered := Render(RenderOptions{}, eighths, sixteenths)
+		if rendered[0].Pulses.String() != "...x...." {
+			t.Errorf("expected the 1st pulse to be triggered, got %s", rendered[0].Pulses)
+		}
+		// the nei pulse is played before the 1st pulse, even if its step index is
+		// higher.
+		if want := "................"; rendered[1].Pulses.String() != want {
+			t.Errorf("expected\n%s, got\n%s", want, rendered[1].Pulses)
+		}
+	})
+
+	t.Run("nil pattern", func(t *testing.T) {
+		patterns := NewFromString(One8, "x...")
+		rendered := Render(RenderOptions{Loops: 2}, nil, patterns[0])
+		if len(rendered) != 2 || rendered[0] != nil || rendered[1].Pulses.String() != "x.......x......." {
+			t.Errorf("expected the nil pattern to be skipped, got %v", rendered)
+		}
+	})
+}
+
+func TestNewFromString_trigModifiers(t *testing.T) {
+	p := NewFromString(One16, "x(25%, 1:2)..x(!fill)x(bogus).x(0%)")[0]
+	if got := p.Pulses.String(); got != "x..xx.." {
+		t.Fatalf("expected the modifiers not to be steps and 0%% trigs to be rests, got %s", got)
+	}
+	if p.Pulses[0].Probability != 25 || p.Pulses[0].Condition != "1:2" {
+		t.Errorf("expected the first pulse to have a 25%% chance on 1:2, got %d%% on %q", p.Pulses[0].Probability, p.Pulses[0].Condition)
+	}
+	if p.Pulses[3].Condition != CondNotFill {
+		t.Errorf("expected the second pulse to have a !fill condition, got %q", p.Pulses[3].Condition)
+	}
+	if p.Pulses[4].Condition != CondNone {
+		t.Errorf("expected the invalid condition to be ignored, got %q", p.Pulses[4].Condition)
+	}
+}